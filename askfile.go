@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// askFileNames are the per-project convention files, in order of preference.
+var askFileNames = []string{".ask.yaml", ".ask.yml", ".askrc"}
+
+// AskFile holds team conventions checked into a repository, e.g.:
+//
+//	instructions: |
+//	  We use pnpm, never npm.
+//	preferred_tools: [pnpm, rg]
+//	forbidden_tools: [npm, yarn]
+//	aliases:
+//	  deploy: make deploy ENV=staging
+//	danger_rules:
+//	  - pattern: '\bkubectl\s+delete\b'
+//	    message: Deleting cluster resources
+//	    severity: high
+//
+// danger_rules are written and compiled like the rules of a rules file (see
+// rulesFileName), with the same fields.
+type AskFile struct {
+	Path           string            `yaml:"-"`
+	Instructions   string            `yaml:"instructions"`
+	PreferredTools []string          `yaml:"preferred_tools"`
+	ForbiddenTools []string          `yaml:"forbidden_tools"`
+	Aliases        map[string]string `yaml:"aliases"`
+	DangerRules    []compiledPattern `yaml:"-"`
+}
+
+var askFiles = make(fileCache[*AskFile])
+
+// loadAskFile finds the nearest .ask.yaml (or .askrc) walking up from dir.
+// It returns nil with no error when the project has none.
+func loadAskFile(dir string) (*AskFile, error) {
+	path := findUp(dir, askFileNames...)
+	if path == "" {
+		return nil, nil
+	}
+	return askFiles.load(path, readAskFile)
+}
+
+func readAskFile(path string) (*AskFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var doc yaml.Node
+	var af AskFile
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	af.Path = path
+	if len(doc.Content) == 0 {
+		return &af, nil
+	}
+	if err := doc.Decode(&af); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	root := doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if key := root.Content[i].Value; key == "danger_rules" {
+			if af.DangerRules, err = compileRules(path, key, root.Content[i+1]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &af, nil
+}
+
+// dangerPatterns returns the file's extra danger rules plus one rule per
+// forbidden tool.
+func (af *AskFile) dangerPatterns() []compiledPattern {
+	if af == nil {
+		return nil
+	}
+	patterns := append([]compiledPattern(nil), af.DangerRules...)
+	for _, tool := range af.ForbiddenTools {
+		patterns = append(patterns, compiledPattern{
+			re:       regexp.MustCompile(`(^|[;&|(]|\bsudo)\s*` + regexp.QuoteMeta(tool) + `(\s|$)`),
+			message:  fmt.Sprintf("%s is forbidden by project conventions (%s)", tool, filepath.Base(af.Path)),
+			severity: "medium",
+			anchored: true,
+			source:   af.Path,
+		})
+	}
+	return patterns
+}
+
+// formatConventions renders the file's contents for inclusion in the prompt,
+// or returns an empty string if there is nothing to add.
+func (af *AskFile) formatConventions() string {
+	if af == nil {
+		return ""
+	}
+	var lines []string
+	if s := strings.TrimSpace(af.Instructions); s != "" {
+		lines = append(lines, s)
+	}
+	if len(af.PreferredTools) > 0 {
+		lines = append(lines, "Preferred tools: "+strings.Join(af.PreferredTools, ", "))
+	}
+	if len(af.ForbiddenTools) > 0 {
+		lines = append(lines, "Never use: "+strings.Join(af.ForbiddenTools, ", "))
+	}
+	if len(af.Aliases) > 0 {
+		names := make([]string, 0, len(af.Aliases))
+		for name := range af.Aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		lines = append(lines, "Project aliases (use them when they fit):")
+		for _, name := range names {
+			lines = append(lines, fmt.Sprintf("  %s = %s", name, af.Aliases[name]))
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return "Project conventions (always follow these):\n" + strings.Join(lines, "\n")
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sampleAskFile = `instructions: |
+  We use pnpm, never npm.
+preferred_tools: [pnpm, rg]
+forbidden_tools: [npm]
+aliases:
+  deploy: make deploy ENV=staging
+  build: pnpm build
+danger_rules:
+  - pattern: '\bkubectl\s+delete\b'
+    message: Deleting cluster resources
+    severity: high
+  - pattern: '\bterraform\s+apply\b'
+`
+
+func TestLoadAskFileNone(t *testing.T) {
+	af, err := loadAskFile(t.TempDir())
+	if err != nil || af != nil {
+		t.Errorf("loadAskFile(empty) = %v, %v; want nil, nil", af, err)
+	}
+	if got := af.formatConventions(); got != "" {
+		t.Errorf("nil formatConventions() = %q, want empty", got)
+	}
+	if got := af.dangerPatterns(); len(got) != 0 {
+		t.Errorf("nil dangerPatterns() = %d patterns, want 0", len(got))
+	}
+}
+
+func TestLoadAskFileWalksUp(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, ".ask.yaml"), []byte(sampleAskFile), 0644)
+	sub := filepath.Join(root, "web", "src")
+	os.MkdirAll(sub, 0755)
+
+	af, err := loadAskFile(sub)
+	if err != nil {
+		t.Fatalf("loadAskFile() error: %v", err)
+	}
+	if af == nil {
+		t.Fatal("loadAskFile() = nil, want config from parent directory")
+	}
+	if af.Path != filepath.Join(root, ".ask.yaml") {
+		t.Errorf("Path = %q, want %q", af.Path, filepath.Join(root, ".ask.yaml"))
+	}
+	if len(af.DangerRules) != 2 {
+		t.Fatalf("DangerRules = %d, want 2", len(af.DangerRules))
+	}
+	if af.DangerRules[1].severity != "medium" {
+		t.Errorf("default severity = %q, want medium", af.DangerRules[1].severity)
+	}
+	if af.DangerRules[1].message == "" {
+		t.Error("default message should be filled in")
+	}
+	if loc := af.DangerRules[0].location(); loc != af.Path+":9" {
+		t.Errorf("location() = %q, want the file and line of the rule", loc)
+	}
+}
+
+func TestLoadAskFileAskrc(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".askrc"), []byte("instructions: use make\n"), 0644)
+
+	af, err := loadAskFile(dir)
+	if err != nil || af == nil {
+		t.Fatalf("loadAskFile(.askrc) = %v, %v", af, err)
+	}
+	if af.Instructions != "use make" {
+		t.Errorf("Instructions = %q", af.Instructions)
+	}
+}
+
+func TestLoadAskFileInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"bad yaml", "aliases: [unclosed", "parsing"},
+		{"bad regex", "danger_rules:\n  - pattern: '('\n", ".ask.yaml:2: rule 1: invalid pattern"},
+		{"unknown field", "danger_rules:\n  - pattern: x\n    severty: high\n", "unknown field \"severty\""},
+		{"not a list", "danger_rules: x\n", "must be a list"},
+		{"missing pattern", "danger_rules:\n  - message: oops\n", "pattern is required"},
+		{"bad severity", "danger_rules:\n  - pattern: x\n    severity: critical\n", "severity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			os.WriteFile(filepath.Join(dir, ".ask.yaml"), []byte(tt.content), 0644)
+			_, err := loadAskFile(dir)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadAskFile() error = %v, want containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAskFileFormatConventions(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".ask.yaml"), []byte(sampleAskFile), 0644)
+	af, _ := loadAskFile(dir)
+
+	got := af.formatConventions()
+	for _, want := range []string{
+		"We use pnpm, never npm.",
+		"Preferred tools: pnpm, rg",
+		"Never use: npm",
+		"build = pnpm build\n  deploy = make deploy ENV=staging",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("formatConventions() missing %q in:\n%s", want, got)
+		}
+	}
+}
+
+func TestAskFileDangerPatterns(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".ask.yaml"), []byte(sampleAskFile), 0644)
+	af, _ := loadAskFile(dir)
+	patterns := af.dangerPatterns()
+
+	matches := func(cmd string) []string {
+		var msgs []string
+		for _, p := range patterns {
+			if p.re.MatchString(cmd) {
+				msgs = append(msgs, p.message)
+			}
+		}
+		return msgs
+	}
+
+	if got := matches("kubectl delete pod web-1"); len(got) != 1 || got[0] != "Deleting cluster resources" {
+		t.Errorf("kubectl delete matched %v", got)
+	}
+	if got := matches("npm install"); len(got) != 1 || !strings.Contains(got[0], "npm is forbidden") {
+		t.Errorf("npm install matched %v", got)
+	}
+	if got := matches("cd web && npm run build"); len(got) != 1 {
+		t.Errorf("chained npm matched %v", got)
+	}
+	if got := matches("pnpm install"); len(got) != 0 {
+		t.Errorf("pnpm install matched %v, want none", got)
+	}
+}
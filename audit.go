@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/syslog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultAuditEntries is how many records "ask audit" shows by default.
+const defaultAuditEntries = 20
+
+// auditStarted is the phase of the record written before a command runs.
+const auditStarted = "started"
+
+// auditRecord is one executed command in the audit log. Unlike the stats,
+// nothing is truncated. Each command gets a record when it starts and one
+// when it ends, with the same ID, so a command that takes ask down with it
+// is still logged.
+type auditRecord struct {
+	ID         string    `json:"id,omitempty"`
+	Phase      string    `json:"phase,omitempty"` // auditStarted, or empty once the command has ended
+	Time       time.Time `json:"time"`
+	User       string    `json:"user"`
+	Host       string    `json:"host"`
+	Cwd        string    `json:"cwd"`
+	Request    string    `json:"request,omitempty"` // the natural-language request, if any
+	Command    string    `json:"command"`
+	Warnings   []string  `json:"warnings,omitempty"` // "severity: message"
+	Policy     string    `json:"policy,omitempty"`
+	Override   bool      `json:"override,omitempty"` // --force overrode the policy
+	ExitCode   int       `json:"exit_code"`
+	DurationMS int64     `json:"duration_ms"`
+}
+
+// auditPath returns the audit log file: ASK_AUDIT_LOG, or ~/.ask/audit.log.
+func auditPath() string {
+	if path := os.Getenv("ASK_AUDIT_LOG"); path != "" {
+		return expandHome(path)
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".ask", "audit.log")
+}
+
+func auditUser() string {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	if sudoUser := os.Getenv("SUDO_USER"); sudoUser != "" && sudoUser != name {
+		name += " (sudo from " + sudoUser + ")"
+	}
+	return name
+}
+
+// exitCode returns the exit code for the error a command returned: -1 if it
+// couldn't be started at all.
+func exitCode(err error) int {
+	var exitErr *exec.ExitError
+	var pathErr *fs.PathError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode()
+	case errors.As(err, &pathErr):
+		return 1 // a failed cd
+	}
+	return -1
+}
+
+// runAudited runs cmd with run and appends it to the audit log, with the
+// request it came from and what the policies decided about it: once before
+// it runs, and again with its exit code.
+func runAudited(request, cmd string, outcome policyOutcome, run func() error) error {
+	cwd, _ := os.Getwd()
+	start := time.Now()
+	rec := auditRecord{
+		Time:     start,
+		User:     auditUser(),
+		Cwd:      cwd,
+		Request:  request,
+		Command:  cmd,
+		Override: outcome.overridden,
+	}
+	rec.Host, _ = os.Hostname()
+	rec.ID = fmt.Sprintf("%s-%d-%d", rec.Host, os.Getpid(), start.UnixNano())
+	for _, w := range outcome.warnings {
+		rec.Warnings = append(rec.Warnings, w.severity+": "+w.message)
+	}
+	if len(rec.Warnings) > 0 {
+		rec.Policy = outcome.policy
+	}
+	started := rec
+	started.Phase = auditStarted
+	writeAudit(started)
+
+	// Ctrl-C and a hangup reach the command from the terminal; ask stays
+	// alive to record how it ended.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGHUP)
+	err := run()
+	signal.Stop(signals)
+
+	rec.ExitCode = exitCode(err)
+	rec.DurationMS = time.Since(start).Milliseconds()
+	writeAudit(rec)
+	return err
+}
+
+// writeAudit appends rec to the audit log, and sends it to syslog if that is
+// on. Failures are reported but never stop a command.
+func writeAudit(rec auditRecord) {
+	if err := appendAudit(auditPath(), rec); err != nil {
+		fmt.Fprintln(os.Stderr, paint(os.Stderr, colorYellow, fmt.Sprintf("warning: audit log: %v", err)))
+	}
+	if os.Getenv("ASK_AUDIT_SYSLOG") != "" {
+		if err := sendAuditSyslog(rec); err != nil {
+			fmt.Fprintln(os.Stderr, paint(os.Stderr, colorYellow, fmt.Sprintf("warning: audit syslog: %v", err)))
+		}
+	}
+}
+
+// appendAudit appends rec to the log at path as one line of JSON. The log is
+// only ever appended to; ask never rewrites or rotates it.
+func appendAudit(path string, rec auditRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	// A single write keeps records from concurrent sessions on separate lines.
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readAudit returns the last n commands in the log at path (all if n <= 0),
+// oldest first. The end record of a command replaces its start record; a
+// start record without one is a command that never finished.
+func readAudit(path string, n int) ([]auditRecord, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []auditRecord
+	started := make(map[string]int) // the index of each unfinished command
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var rec auditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if i, ok := started[rec.ID]; ok && rec.Phase == "" {
+			records[i] = rec
+			delete(started, rec.ID)
+			continue
+		}
+		if rec.Phase == auditStarted && rec.ID != "" {
+			started[rec.ID] = len(records)
+		}
+		records = append(records, rec)
+	}
+	if n > 0 && len(records) > n {
+		records = records[len(records)-n:]
+	}
+	return records, scanner.Err()
+}
+
+// auditSeverity is the syslog severity of a record: warning for flagged or
+// overridden commands, notice otherwise.
+func auditSeverity(rec auditRecord) syslog.Priority {
+	if len(rec.Warnings) > 0 || rec.Override {
+		return syslog.LOG_WARNING
+	}
+	return syslog.LOG_NOTICE
+}
+
+// auditMessage formats rec as key=value pairs, the way syslog and journald
+// tools parse them.
+func auditMessage(rec auditRecord) string {
+	fields := []string{
+		"user=" + strconv.Quote(rec.User),
+		"cwd=" + strconv.Quote(rec.Cwd),
+		"command=" + strconv.Quote(rec.Command),
+	}
+	if rec.Phase == auditStarted {
+		fields = append(fields, "phase="+rec.Phase)
+	} else {
+		fields = append(fields, "exit="+strconv.Itoa(rec.ExitCode), "duration_ms="+strconv.FormatInt(rec.DurationMS, 10))
+	}
+	if rec.Request != "" {
+		fields = append(fields, "request="+strconv.Quote(rec.Request))
+	}
+	if len(rec.Warnings) > 0 {
+		fields = append(fields, "warnings="+strconv.Quote(strings.Join(rec.Warnings, "; ")), "policy="+rec.Policy)
+	}
+	if rec.Override {
+		fields = append(fields, "override=true")
+	}
+	if rec.ID != "" {
+		fields = append(fields, "id="+rec.ID)
+	}
+	return strings.Join(fields, " ")
+}
+
+// formatSyslog formats rec as an RFC 5424 line with the user facility, which
+// syslog daemons, journald and log shippers accept as is.
+func formatSyslog(rec auditRecord) string {
+	host := rec.Host
+	if host == "" {
+		host = "-"
+	}
+	pri := syslog.LOG_USER | auditSeverity(rec)
+	return fmt.Sprintf("<%d>1 %s %s ask - audit - %s", pri, rec.Time.Format(time.RFC3339Nano), host, auditMessage(rec))
+}
+
+// sendAuditSyslog sends rec to the local syslog daemon, which is journald on
+// most Linux systems.
+func sendAuditSyslog(rec auditRecord) error {
+	w, err := syslog.New(syslog.LOG_USER|syslog.LOG_NOTICE, "ask")
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	if auditSeverity(rec) == syslog.LOG_WARNING {
+		return w.Warning(auditMessage(rec))
+	}
+	return w.Notice(auditMessage(rec))
+}
+
+// printAudit writes records for people to read.
+func printAudit(w io.Writer, records []auditRecord) {
+	for _, rec := range records {
+		status := fmt.Sprintf("exit %d", rec.ExitCode)
+		duration := (time.Duration(rec.DurationMS) * time.Millisecond).String()
+		if rec.Phase == auditStarted {
+			status, duration = "did not finish", "-"
+		}
+		if rec.ExitCode != 0 || rec.Phase == auditStarted {
+			status = paint(os.Stdout, colorRed, status)
+		}
+		fmt.Fprintf(w, "%s  %s  %s  %s  %s\n", rec.Time.Local().Format("2006-01-02 15:04:05"), rec.User, status, duration, rec.Cwd)
+		fmt.Fprintf(w, "  $ %s\n", rec.Command)
+		if rec.Request != "" {
+			fmt.Fprintf(w, "  request: %s\n", rec.Request)
+		}
+		for _, warning := range rec.Warnings {
+			fmt.Fprintln(w, paint(os.Stdout, colorYellow, "  ⚠ "+warning))
+		}
+		if rec.Override {
+			fmt.Fprintln(w, paint(os.Stdout, colorRed, fmt.Sprintf("  policy %s overridden with --force", rec.Policy)))
+		}
+	}
+}
+
+// runAuditCommand implements "ask audit": it shows the most recent records
+// in the audit log, as text, JSON lines or syslog lines.
+func runAuditCommand(args []string) error {
+	flags := flag.NewFlagSet("audit", flag.ContinueOnError)
+	n := flags.Int("n", defaultAuditEntries, "Show the last `count` commands (0 for all)")
+	format := flags.String("format", "text", "Output format: text, json or syslog")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("usage: ask audit [-n count] [--format text|json|syslog]")
+	}
+
+	path := auditPath()
+	records, err := readAudit(path, *n)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "text":
+		if len(records) == 0 {
+			fmt.Printf("No commands in %s.\n", path)
+			return nil
+		}
+		printAudit(os.Stdout, records)
+	case "json":
+		for _, rec := range records {
+			data, _ := json.Marshal(rec)
+			fmt.Println(string(data))
+		}
+	case "syslog":
+		for _, rec := range records {
+			fmt.Println(formatSyslog(rec))
+		}
+	default:
+		return fmt.Errorf("unknown format %q (want text, json or syslog)", *format)
+	}
+	return nil
+}
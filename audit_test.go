@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRunAudited(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "audit.log")
+	t.Setenv("ASK_AUDIT_LOG", log)
+	t.Setenv("ASK_AUDIT_SYSLOG", "")
+	dir, _ := filepath.EvalSymlinks(planDir(t))
+
+	outcome := policyOutcome{policy: policyBlock, allowed: true, overridden: true,
+		warnings: []compiledPattern{{message: "Recursive force delete", severity: "high"}}}
+	runAudited("delete the build dir", "rm -rf build", outcome, func() error { return nil })
+	err := runAudited("", "exit 3", policyOutcome{policy: policyWarn, allowed: true}, func() error {
+		_, _, err := executeCommand("exit 3")
+		return err
+	})
+	if err == nil {
+		t.Error("runAudited should return the command's error")
+	}
+
+	records, err := readAudit(log, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	first, second := records[0], records[1]
+	if first.Request != "delete the build dir" || first.Command != "rm -rf build" || first.Cwd != dir ||
+		!first.Override || first.Policy != policyBlock || first.ExitCode != 0 {
+		t.Errorf("first record = %+v", first)
+	}
+	if len(first.Warnings) != 1 || first.Warnings[0] != "high: Recursive force delete" {
+		t.Errorf("warnings = %q", first.Warnings)
+	}
+	if first.User == "" || first.Time.IsZero() {
+		t.Errorf("record should have a user and time: %+v", first)
+	}
+	if second.ExitCode != 3 || second.Override || second.Policy != "" || second.Request != "" {
+		t.Errorf("second record = %+v", second)
+	}
+	if info, err := os.Stat(log); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("audit log should be private, got %v, %v", info.Mode().Perm(), err)
+	}
+}
+
+func TestRunAuditedLogsBeforeRunning(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "audit.log")
+	t.Setenv("ASK_AUDIT_LOG", log)
+	t.Setenv("ASK_AUDIT_SYSLOG", "")
+
+	runAudited("", "sleep 60", policyOutcome{}, func() error {
+		records, err := readAudit(log, 0)
+		if err != nil || len(records) != 1 || records[0].Phase != auditStarted || records[0].Command != "sleep 60" {
+			t.Errorf("before the command ends the log should have its start, got %+v, %v", records, err)
+		}
+		// Ctrl-C in the terminal reaches ask too; it must survive it
+		syscall.Kill(os.Getpid(), syscall.SIGINT)
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	})
+
+	records, err := readAudit(log, 0)
+	if err != nil || len(records) != 1 || records[0].Phase != "" || records[0].ID == "" {
+		t.Errorf("the end record should replace the start record, got %+v, %v", records, err)
+	}
+	data, _ := os.ReadFile(log)
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("the log has %d lines, want a start and an end record", lines)
+	}
+
+	appendAudit(log, auditRecord{ID: "killed", Phase: auditStarted, Command: "reboot"})
+	records, _ = readAudit(log, 1)
+	if len(records) != 1 || records[0].Phase != auditStarted {
+		t.Errorf("a command that never ended should read as started, got %+v", records)
+	}
+	out := captureStdout(t, func() { printAudit(os.Stdout, records) })
+	if !strings.Contains(out, "did not finish") {
+		t.Errorf("printAudit() should say the command did not finish:\n%s", out)
+	}
+}
+
+func TestReadAuditLast(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "audit.log")
+	for _, cmd := range []string{"one", "two", "three"} {
+		if err := appendAudit(log, auditRecord{Command: cmd}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := readAudit(log, 2)
+	if err != nil || len(records) != 2 || records[0].Command != "two" || records[1].Command != "three" {
+		t.Errorf("readAudit(log, 2) = %+v, %v", records, err)
+	}
+	if records, err := readAudit(filepath.Join(t.TempDir(), "missing"), 0); err != nil || records != nil {
+		t.Errorf("a missing log should read as empty, got %v, %v", records, err)
+	}
+
+	os.WriteFile(log, []byte("{\"command\":\"ok\"}\nnot json\n"), 0600)
+	if _, err := readAudit(log, 0); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("a corrupt line should be reported with its line number, got %v", err)
+	}
+}
+
+func TestFormatSyslog(t *testing.T) {
+	rec := auditRecord{
+		Time:     time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		User:     "alice",
+		Host:     "jump1",
+		Cwd:      "/srv",
+		Request:  `remove "old" logs`,
+		Command:  "rm -rf logs",
+		Warnings: []string{"high: Recursive force delete"},
+		Policy:   policyConfirm,
+		ExitCode: 0,
+	}
+	want := `<12>1 2026-03-01T12:00:00Z jump1 ask - audit - user="alice" cwd="/srv" command="rm -rf logs" exit=0 duration_ms=0 ` +
+		`request="remove \"old\" logs" warnings="high: Recursive force delete" policy=confirm`
+	if got := formatSyslog(rec); got != want {
+		t.Errorf("formatSyslog() =\n%s\nwant\n%s", got, want)
+	}
+
+	rec.Warnings, rec.Host = nil, ""
+	if got := formatSyslog(rec); !strings.HasPrefix(got, "<13>1 2026-03-01T12:00:00Z - ask") {
+		t.Errorf("an unflagged record should be a notice with a nil host, got %s", got)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	_, _, err := executeCommand("exit 7")
+	if got := exitCode(err); got != 7 {
+		t.Errorf("exitCode(exit 7) = %d", got)
+	}
+	if got := exitCode(os.Chdir(filepath.Join(t.TempDir(), "missing"))); got != 1 {
+		t.Errorf("exitCode(failed cd) = %d, want 1", got)
+	}
+	if got := exitCode(errors.New("could not start")); got != -1 {
+		t.Errorf("exitCode(other) = %d, want -1", got)
+	}
+	if exitCode(nil) != 0 {
+		t.Error("exitCode(nil) should be 0")
+	}
+}
@@ -0,0 +1,618 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// maxBlastPaths caps how many affected paths are counted per command.
+	maxBlastPaths = 100000
+	// blastTimeout bounds the time spent walking directories.
+	blastTimeout = 2 * time.Second
+	// blastSampleSize is how many affected paths are listed.
+	blastSampleSize = 5
+)
+
+// blastEntry is what one destructive command would affect, worked out from
+// the file system without running it.
+type blastEntry struct {
+	command    string   // the command, as rendered for the patterns
+	action     string   // "deletes", "moves", "overwrites", ...
+	files      int      // files affected (anything but directories)
+	dirs       int      // directories affected
+	bytes      int64    // total size of the files
+	paths      []string // every affected path, up to maxBlastPaths
+	partial    bool     // counting stopped early: there may be more
+	upperBound bool     // find tests weren't all evaluated: there may be fewer
+	unresolved []string // operands that can't be known without running it
+	modified   []string // affected git-tracked files with uncommitted changes
+	overwrites bool     // replaces the contents of existing files
+	metadata   bool     // only changes permissions or ownership
+}
+
+// blastRadius works out what the file-destroying commands in cmd would
+// affect: rm, mv, chmod -R, chown -R, find -delete or -exec rm, git clean,
+// and redirects that truncate a file. Globs and paths are expanded locally;
+// nothing in cmd is run. Paths are resolved against the current directory.
+func blastRadius(cmd string) []*blastEntry {
+	list, err := parseShell(cmd)
+	if err != nil {
+		return nil
+	}
+	cwd, _ := os.Getwd()
+	deadline := time.Now().Add(blastTimeout)
+	var entries []*blastEntry
+	for _, c := range list.commands() {
+		if c.Group != nil {
+			continue
+		}
+		words := c.Words
+		if len(words) > 0 {
+			if inner := unwrapCommand(filepath.Base(words[0].Value), words); len(inner) > 0 {
+				words = inner
+			}
+		}
+		if e := blastOfCommand(words, cwd, deadline); e != nil {
+			entries = append(entries, e)
+		}
+		if e := blastOfRedirects(words, c.Redirects, cwd); e != nil {
+			entries = append(entries, e)
+		}
+	}
+	for _, e := range entries {
+		e.modified = modifiedGitFiles(cwd, e.paths)
+	}
+	return entries
+}
+
+func blastOfCommand(words []shellWord, cwd string, deadline time.Time) *blastEntry {
+	if len(words) == 0 {
+		return nil
+	}
+	name := filepath.Base(words[0].Value)
+	flags, operands := splitOperands(words[1:])
+	recursive := hasFlag(flags, 'r', "--recursive") || hasFlag(flags, 'R', "--recursive")
+	e := &blastEntry{command: renderInvocation(words, nil)}
+
+	switch name {
+	case "rm":
+		e.action = "deletes"
+		for _, op := range operands {
+			e.addOperand(op, cwd, recursive, deadline)
+		}
+	case "mv":
+		if len(operands) < 2 {
+			return nil
+		}
+		e.action = "moves"
+		for _, op := range operands[:len(operands)-1] {
+			e.addOperand(op, cwd, true, deadline)
+		}
+		// a single source replaces an existing destination file
+		if len(operands) == 2 {
+			if paths, ok := expandOperand(operands[1], cwd); ok && len(paths) == 1 {
+				if info, err := os.Lstat(paths[0]); err == nil && !info.IsDir() {
+					e.action = "moves (overwriting " + displayPath(paths[0], cwd) + ")"
+					e.overwrites = true
+					e.add(paths[0], info)
+				}
+			}
+		}
+	case "chmod", "chown", "chgrp":
+		if !hasFlag(flags, 'R', "--recursive") || len(operands) < 2 {
+			return nil
+		}
+		e.action = map[string]string{"chmod": "changes permissions of", "chown": "changes the owner of", "chgrp": "changes the group of"}[name]
+		e.metadata = true
+		for _, op := range operands[1:] {
+			e.addOperand(op, cwd, true, deadline)
+		}
+	case "find":
+		if !findDeletes(words) {
+			return nil
+		}
+		e.action = "deletes"
+		e.addFindMatches(words, cwd, deadline)
+	case "git":
+		if len(words) < 2 || words[1].Value != "clean" {
+			return nil
+		}
+		e.action = "deletes"
+		e.addGitClean(words, cwd, deadline)
+	default:
+		return nil
+	}
+	if e.files+e.dirs == 0 && len(e.unresolved) == 0 {
+		return nil
+	}
+	return e
+}
+
+// blastOfRedirects reports the files that > redirects of a command would
+// truncate.
+func blastOfRedirects(words []shellWord, redirects []shellRedirect, cwd string) *blastEntry {
+	e := &blastEntry{command: renderInvocation(words, redirects), action: "truncates", overwrites: true}
+	paths, unresolved := truncatedFiles(redirects, cwd)
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			e.add(path, info)
+		}
+	}
+	e.unresolved = unresolved
+	if e.files == 0 && len(e.unresolved) == 0 {
+		return nil
+	}
+	return e
+}
+
+// truncatedFiles returns the existing, non-empty files that >, >| and &>
+// redirects would truncate, and the targets that can't be resolved without
+// running the command. Appending (>>) and devices like /dev/null are fine.
+func truncatedFiles(redirects []shellRedirect, cwd string) ([]string, []string) {
+	var paths, unresolved []string
+	for _, r := range redirects {
+		op := strings.TrimLeft(r.Op, "0123456789")
+		if op != ">" && op != ">|" && op != "&>" {
+			continue
+		}
+		targets, ok := expandOperand(r.Target, cwd)
+		if !ok {
+			unresolved = append(unresolved, r.Target.Raw)
+			continue
+		}
+		for _, path := range targets {
+			if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() && info.Size() > 0 {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths, unresolved
+}
+
+// splitOperands separates flags from operands. As in GNU tools, flags may
+// follow operands; everything after "--" is an operand.
+func splitOperands(words []shellWord) ([]string, []shellWord) {
+	var flags []string
+	var operands []shellWord
+	for i, w := range words {
+		switch {
+		case w.Value == "--" && !w.Quoted:
+			return flags, append(operands, words[i+1:]...)
+		case strings.HasPrefix(w.Value, "-") && len(w.Value) > 1 && !w.Quoted:
+			flags = append(flags, w.Value)
+		default:
+			operands = append(operands, w)
+		}
+	}
+	return flags, operands
+}
+
+// hasFlag reports whether flags include the short flag letter, alone or
+// combined (-rf), or the long flag.
+func hasFlag(flags []string, letter rune, long string) bool {
+	for _, f := range flags {
+		if f == long || !strings.HasPrefix(f, "--") && strings.ContainsRune(f[1:], letter) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	shellVarRe  = regexp.MustCompile(`\$\{?([A-Za-z_][A-Za-z0-9_]*)\}?`)
+	globCharsRe = regexp.MustCompile(`[*?\[]`)
+)
+
+// expandOperand resolves a word to the paths it names: ~ and set variables
+// are expanded, and unquoted globs matched. It reports false if the word
+// can't be resolved without running the command.
+func expandOperand(w shellWord, cwd string) ([]string, bool) {
+	if len(w.Substs) > 0 {
+		return nil, false
+	}
+	value := w.Value
+	resolved := true
+	value = shellVarRe.ReplaceAllStringFunc(value, func(v string) string {
+		name := shellVarRe.FindStringSubmatch(v)[1]
+		val, ok := os.LookupEnv(name)
+		resolved = resolved && ok
+		return val
+	})
+	if !resolved || value == "" {
+		return nil, false
+	}
+	value = expandHome(value)
+	if !filepath.IsAbs(value) {
+		value = filepath.Join(cwd, value)
+	}
+	if w.Quoted || !globCharsRe.MatchString(value) {
+		return []string{value}, true
+	}
+	matches, err := filepath.Glob(value)
+	if err != nil {
+		return nil, false
+	}
+	visible := matches[:0]
+	for _, m := range matches {
+		if !matchesHidden(value, m) {
+			visible = append(visible, m)
+		}
+	}
+	return visible, true
+}
+
+// matchesHidden reports whether match has a dotfile where pattern has a glob
+// that doesn't start with a dot. filepath.Glob matches those, but the shell
+// doesn't: rm * leaves .git alone.
+func matchesHidden(pattern, match string) bool {
+	patterns := strings.Split(pattern, string(filepath.Separator))
+	names := strings.Split(match, string(filepath.Separator))
+	for i := range names {
+		if i < len(patterns) && strings.HasPrefix(names[i], ".") && !strings.HasPrefix(patterns[i], ".") &&
+			globCharsRe.MatchString(patterns[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// addOperand adds what an operand names, including everything under it when
+// the command is recursive.
+func (e *blastEntry) addOperand(w shellWord, cwd string, recursive bool, deadline time.Time) {
+	paths, ok := expandOperand(w, cwd)
+	if !ok {
+		e.unresolved = append(e.unresolved, w.Raw)
+		return
+	}
+	for _, path := range paths {
+		info, err := os.Lstat(path)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			if recursive {
+				e.walk(path, deadline, nil)
+			}
+			continue
+		}
+		e.add(path, info)
+	}
+}
+
+// add counts one affected path.
+func (e *blastEntry) add(path string, info fs.FileInfo) {
+	if len(e.paths) >= maxBlastPaths {
+		e.partial = true
+		return
+	}
+	e.paths = append(e.paths, path)
+	if info.IsDir() {
+		e.dirs++
+		return
+	}
+	e.files++
+	if info.Mode().IsRegular() {
+		e.bytes += info.Size()
+	}
+}
+
+// walk adds root and everything under it that match accepts (nil accepts
+// all), stopping at the path limit or the deadline.
+func (e *blastEntry) walk(root string, deadline time.Time, match func(path string, d fs.DirEntry, depth int) bool) {
+	rootDepth := strings.Count(filepath.Clean(root), string(filepath.Separator))
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if len(e.paths) >= maxBlastPaths || time.Now().After(deadline) {
+			e.partial = true
+			return filepath.SkipAll
+		}
+		if match != nil && !match(path, d, strings.Count(path, string(filepath.Separator))-rootDepth) {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			e.add(path, info)
+		}
+		return nil
+	})
+}
+
+// findDeletes reports whether a find command deletes what it matches, with
+// -delete or -exec rm.
+func findDeletes(words []shellWord) bool {
+	for _, w := range words {
+		if w.Value == "-delete" {
+			return true
+		}
+	}
+	for _, exec := range findExecCommands(words) {
+		if filepath.Base(exec[0].Value) == "rm" {
+			return true
+		}
+	}
+	return false
+}
+
+// addFindMatches walks find's starting points and adds the paths its
+// expression matches. Only -name, -iname, -type, -maxdepth, -mindepth and
+// -empty are evaluated; with any other test, or -o and parentheses, the
+// count is an upper bound.
+func (e *blastEntry) addFindMatches(words []shellWord, cwd string, deadline time.Time) {
+	var roots []shellWord
+	i := 1
+	for ; i < len(words); i++ {
+		v := words[i].Value
+		if strings.HasPrefix(v, "-") && !words[i].Quoted || v == "(" || v == "!" {
+			break
+		}
+		roots = append(roots, words[i])
+	}
+	if len(roots) == 0 {
+		roots = []shellWord{{Raw: ".", Value: "."}}
+	}
+
+	var tests []func(path string, d fs.DirEntry) bool
+	maxDepth, minDepth := -1, 0
+	for ; i < len(words); i++ {
+		v := words[i].Value
+		arg := ""
+		if i+1 < len(words) {
+			arg = words[i+1].Value
+		}
+		switch v {
+		case "-name", "-iname":
+			pattern, fold := arg, v == "-iname"
+			tests = append(tests, func(path string, d fs.DirEntry) bool {
+				name := filepath.Base(path)
+				if fold {
+					return matchGlob(strings.ToLower(pattern), strings.ToLower(name))
+				}
+				return matchGlob(pattern, name)
+			})
+			i++
+		case "-type":
+			kind := arg
+			tests = append(tests, func(path string, d fs.DirEntry) bool {
+				switch kind {
+				case "f":
+					return d.Type().IsRegular()
+				case "d":
+					return d.IsDir()
+				case "l":
+					return d.Type()&fs.ModeSymlink != 0
+				}
+				return true
+			})
+			i++
+		case "-maxdepth", "-mindepth":
+			var n int
+			if _, err := fmt.Sscan(arg, &n); err == nil {
+				if v == "-maxdepth" {
+					maxDepth = n
+				} else {
+					minDepth = n
+				}
+			}
+			i++
+		case "-empty":
+			tests = append(tests, func(path string, d fs.DirEntry) bool {
+				if d.IsDir() {
+					entries, err := os.ReadDir(path)
+					return err == nil && len(entries) == 0
+				}
+				info, err := d.Info()
+				return err == nil && info.Size() == 0
+			})
+		case "-delete", "-print", "-print0", "-depth", "-xdev", "-mount", "-ls":
+		case "-exec", "-execdir", "-ok", "-okdir":
+			for i < len(words) && words[i].Value != ";" && words[i].Value != "+" {
+				i++
+			}
+		case "-o", "-or", "!", "-not", "(", ")", ",":
+			e.upperBound = true
+			tests = nil
+			i = len(words) // can't evaluate: count everything
+		default:
+			// a test we don't evaluate, such as -mtime 7 or -size +1M
+			e.upperBound = true
+			if arg != "" && !strings.HasPrefix(arg, "-") {
+				i++
+			}
+		}
+	}
+
+	match := func(path string, d fs.DirEntry, depth int) bool {
+		if depth < minDepth || maxDepth >= 0 && depth > maxDepth {
+			return false
+		}
+		for _, test := range tests {
+			if !test(path, d) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, root := range roots {
+		paths, ok := expandOperand(root, cwd)
+		if !ok {
+			e.unresolved = append(e.unresolved, root.Raw)
+			continue
+		}
+		for _, path := range paths {
+			e.walk(path, deadline, match)
+		}
+	}
+}
+
+// matchGlob matches name against a find -name pattern.
+func matchGlob(pattern, name string) bool {
+	ok, _ := filepath.Match(pattern, name)
+	return ok
+}
+
+// addGitClean adds what "git clean" would remove, as listed by its dry run
+// with the same options.
+func (e *blastEntry) addGitClean(words []shellWord, cwd string, deadline time.Time) {
+	args := []string{"clean", "--dry-run"}
+	for _, w := range words[2:] {
+		v := w.Value
+		switch {
+		case v == "--force" || v == "--interactive" || v == "-f" || v == "-i" || v == "-ff":
+			continue
+		case strings.HasPrefix(v, "-") && !strings.HasPrefix(v, "--"):
+			v = strings.NewReplacer("f", "", "i", "").Replace(v)
+			if v == "-" {
+				continue
+			}
+		}
+		args = append(args, v)
+	}
+	c := exec.Command("git", args...)
+	c.Dir = cwd
+	out, err := c.Output()
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		rel, ok := strings.CutPrefix(line, "Would remove ")
+		if !ok {
+			continue
+		}
+		path := filepath.Join(cwd, rel)
+		info, err := os.Lstat(path)
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			e.walk(path, deadline, nil)
+		} else {
+			e.add(path, info)
+		}
+	}
+}
+
+// modifiedGitFiles returns the paths that are tracked by git and have
+// uncommitted changes.
+func modifiedGitFiles(cwd string, paths []string) []string {
+	if len(paths) == 0 || findUp(cwd, ".git") == "" {
+		return nil
+	}
+	c := exec.Command("git", "status", "--porcelain", "-z", "--untracked-files=no")
+	c.Dir = cwd
+	out, err := c.Output()
+	if err != nil {
+		return nil
+	}
+	top := exec.Command("git", "rev-parse", "--show-toplevel")
+	top.Dir = cwd
+	root, err := top.Output()
+	if err != nil {
+		return nil
+	}
+	changed := make(map[string]bool)
+	for _, entry := range bytes.Split(out, []byte{0}) {
+		if len(entry) > 3 {
+			changed[filepath.Join(strings.TrimSpace(string(root)), string(entry[3:]))] = true
+		}
+	}
+	// git reports real paths; ours may go through a symlink (/tmp on macOS)
+	realDirs := make(map[string]string)
+	var modified []string
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		if _, ok := realDirs[dir]; !ok {
+			realDirs[dir], _ = filepath.EvalSymlinks(dir)
+		}
+		if changed[path] || changed[filepath.Join(realDirs[dir], filepath.Base(path))] {
+			modified = append(modified, path)
+		}
+	}
+	return modified
+}
+
+// displayPath shows path relative to cwd when it is inside it.
+func displayPath(path, cwd string) string {
+	if rel, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// humanSize formats a byte count as "512 B", "1.2 MB" and so on.
+func humanSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// printBlastRadius shows what each entry affects: counts, size, a sample of
+// paths, and a note about git-tracked files with uncommitted changes.
+func printBlastRadius(entries []*blastEntry) {
+	cwd, _ := os.Getwd()
+	for _, e := range entries {
+		var counts []string
+		if e.files > 0 || e.dirs == 0 {
+			counts = append(counts, plural(e.files, "file"))
+		}
+		if e.dirs > 0 {
+			counts = append(counts, plural(e.dirs, "directory"))
+		}
+		summary := strings.Join(counts, " and ")
+		switch {
+		case e.partial:
+			summary = "at least " + summary
+		case e.upperBound:
+			summary = "up to " + summary
+		}
+		if e.bytes > 0 {
+			summary += fmt.Sprintf(" (%s)", humanSize(e.bytes))
+		}
+		fmt.Fprintf(os.Stderr, "  %s %s %s\n", paint(os.Stderr, colorCyan, "↳ "+e.command+":"), e.action, summary)
+		for _, path := range e.paths[:min(blastSampleSize, len(e.paths))] {
+			fmt.Fprintf(os.Stderr, "      %s\n", displayPath(path, cwd))
+		}
+		if len(e.paths) > blastSampleSize {
+			fmt.Fprintf(os.Stderr, "      ... and %d more\n", len(e.paths)-blastSampleSize)
+		}
+		if len(e.unresolved) > 0 {
+			fmt.Fprintf(os.Stderr, "      not counted (only known when it runs): %s\n", strings.Join(e.unresolved, " "))
+		}
+		if len(e.modified) > 0 {
+			var shown []string
+			for _, path := range e.modified[:min(blastSampleSize, len(e.modified))] {
+				shown = append(shown, displayPath(path, cwd))
+			}
+			note := fmt.Sprintf("  ⚠ %s tracked by git with uncommitted changes: %s", plural(len(e.modified), "file"), strings.Join(shown, ", "))
+			if len(e.modified) > blastSampleSize {
+				note += ", ..."
+			}
+			fmt.Fprintln(os.Stderr, paint(os.Stderr, colorRed, note))
+		}
+	}
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	if strings.HasSuffix(noun, "y") {
+		return fmt.Sprintf("%d %sies", n, strings.TrimSuffix(noun, "y"))
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
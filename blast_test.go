@@ -0,0 +1,135 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// blastDir creates files (path: content) in a temporary directory and
+// changes into it.
+func blastDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	orig, _ := os.Getwd()
+	os.Chdir(dir)
+	t.Cleanup(func() { os.Chdir(orig) })
+	return dir
+}
+
+func TestBlastRadius(t *testing.T) {
+	blastDir(t, map[string]string{
+		"build/a.o":       "aaaa",
+		"build/sub/b.o":   "bb",
+		"app.log":         "log",
+		"err.log":         "",
+		".hidden.log":     "hidden",
+		"src/x.tmp":       "x",
+		"src/deep/y.tmp":  "y",
+		"notes.txt":       "keep",
+		"dest.txt":        "old",
+		"src/main.go":     "package main",
+		"src/deep/z.go":   "package deep",
+		"empty/.keep.tmp": "",
+	})
+	t.Setenv("BUILD_DIR", "build")
+	os.Unsetenv("ASK_TEST_UNSET")
+
+	tests := []struct {
+		cmd        string
+		files      int
+		dirs       int
+		bytes      int64
+		action     string
+		upperBound bool
+		unresolved bool
+	}{
+		{"rm -rf build", 2, 2, 6, "deletes", false, false},
+		{"sudo rm -r $BUILD_DIR", 2, 2, 6, "deletes", false, false},
+		{"rm *.log", 2, 0, 3, "deletes", false, false},
+		{"rm .*.log", 1, 0, 6, "deletes", false, false},
+		{"rm '*.log'", 0, 0, 0, "", false, false},
+		{"rm build", 0, 0, 0, "", false, false},
+		{"rm -f $ASK_TEST_UNSET/x", 0, 0, 0, "deletes", false, true},
+		{"rm -f $(cat list)", 0, 0, 0, "deletes", false, true},
+		{"find . -name '*.tmp' -delete", 3, 0, 2, "deletes", false, false},
+		{"find src -maxdepth 1 -name '*.tmp' -exec rm {} +", 1, 0, 1, "deletes", false, false},
+		{"find src -name '*.tmp' -mtime +7 -delete", 2, 0, 2, "deletes", true, false},
+		{"find . -name '*.go'", 0, 0, 0, "", false, false},
+		{"chmod -R 755 src", 4, 2, 26, "changes permissions of", false, false},
+		{"chmod 755 src", 0, 0, 0, "", false, false},
+		{"mv notes.txt dest.txt", 2, 0, 7, "moves (overwriting dest.txt)", false, false},
+		{"echo hi > notes.txt", 1, 0, 4, "truncates", false, false},
+		{"echo hi > err.log", 0, 0, 0, "", false, false},
+		{"echo hi >> notes.txt", 0, 0, 0, "", false, false},
+	}
+	for _, tt := range tests {
+		entries := blastRadius(tt.cmd)
+		if tt.action == "" {
+			if len(entries) != 0 {
+				t.Errorf("blastRadius(%q) = %+v, want nothing", tt.cmd, entries[0])
+			}
+			continue
+		}
+		if len(entries) != 1 {
+			t.Errorf("blastRadius(%q) returned %d entries, want 1", tt.cmd, len(entries))
+			continue
+		}
+		e := entries[0]
+		if e.files != tt.files || e.dirs != tt.dirs || e.bytes != tt.bytes || e.action != tt.action ||
+			e.upperBound != tt.upperBound || len(e.unresolved) > 0 != tt.unresolved {
+			t.Errorf("blastRadius(%q) = %d files, %d dirs, %d bytes, %q, upper bound %v, unresolved %q",
+				tt.cmd, e.files, e.dirs, e.bytes, e.action, e.upperBound, e.unresolved)
+		}
+	}
+}
+
+func TestBlastRadiusGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := blastDir(t, map[string]string{"tracked.txt": "v1", "clean.txt": "v1"})
+	git := func(args ...string) {
+		c := exec.Command("git", append([]string{"-c", "user.name=t", "-c", "user.email=t@t", "-c", "commit.gpgsign=false"}, args...)...)
+		c.Dir = dir
+		if out, err := c.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	git("init", "-q")
+	git("add", ".")
+	git("commit", "-qm", "init")
+	os.WriteFile(filepath.Join(dir, "tracked.txt"), []byte("v2"), 0644)
+	os.MkdirAll(filepath.Join(dir, "junk"), 0755)
+	os.WriteFile(filepath.Join(dir, "junk", "a"), []byte("aa"), 0644)
+	os.WriteFile(filepath.Join(dir, "untracked.txt"), []byte("u"), 0644)
+
+	entries := blastRadius("rm -f *.txt")
+	if len(entries) != 1 || entries[0].files != 3 {
+		t.Fatalf("rm -f *.txt: got %+v", entries)
+	}
+	if got := entries[0].modified; len(got) != 1 || filepath.Base(got[0]) != "tracked.txt" {
+		t.Errorf("modified = %q, want tracked.txt", got)
+	}
+
+	entries = blastRadius("git clean -fd")
+	if len(entries) != 1 || entries[0].files != 2 || entries[0].dirs != 1 {
+		t.Fatalf("git clean -fd: got %+v", entries)
+	}
+
+	out := captureStderr(t, func() { printBlastRadius(blastRadius("rm -f *.txt")) })
+	for _, want := range []string{"deletes 3 files (5 B)", "tracked.txt", "1 file tracked by git with uncommitted changes"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("printBlastRadius output missing %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// shellDialect is the shell syntax generated commands are written in, and
+// the interpreter they are executed with.
+type shellDialect string
+
+const (
+	dialectSh   shellDialect = "sh"
+	dialectBash shellDialect = "bash"
+	dialectZsh  shellDialect = "zsh"
+	dialectFish shellDialect = "fish"
+)
+
+// targetDialect is set once at startup by detectDialect.
+var targetDialect = dialectSh
+
+// dialectRules are extra prompt rules that keep the model's syntax in line
+// with the target shell.
+var dialectRules = map[shellDialect][]string{
+	dialectSh: {
+		"Use POSIX sh syntax only: no [[ ]], no arrays, no brace expansion, no 'function' keyword",
+	},
+	dialectBash: {
+		"Use bash syntax; [[ ]], arrays, brace expansion and $(...) are available",
+	},
+	dialectZsh: {
+		"Use zsh syntax; unmatched globs are an error, so quote patterns passed to other programs (e.g., find -name '*.go')",
+		"Remember zsh arrays are 1-indexed and unquoted variables are not word-split",
+	},
+	dialectFish: {
+		"Use fish syntax, not bash: set VAR value (set -x to export) instead of VAR=value or export",
+		"Use (cmd) instead of $(cmd) or backticks, and $status instead of $?",
+		"Loops and conditionals end with 'end': for f in *.txt; echo $f; end",
+		"Chain with 'and'/'or' (or && and || on fish 3+); there is no [[ ]] (use test)",
+	},
+}
+
+// parseDialect maps a shell name or path (e.g. "/bin/zsh", "-bash") to a
+// dialect. POSIX-compatible shells such as dash and ksh map to sh.
+func parseDialect(name string) (shellDialect, bool) {
+	name = strings.TrimPrefix(filepath.Base(strings.TrimSpace(name)), "-")
+	switch name {
+	case "bash":
+		return dialectBash, true
+	case "zsh":
+		return dialectZsh, true
+	case "fish":
+		return dialectFish, true
+	case "sh", "dash", "ash", "ksh", "mksh", "busybox":
+		return dialectSh, true
+	}
+	return "", false
+}
+
+// parentProcessName returns the command name of ask's parent process.
+func parentProcessName() string {
+	ppid := os.Getppid()
+	if runtime.GOOS == "linux" {
+		if data, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", ppid)); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+	out, err := exec.Command("ps", "-o", "comm=", "-p", strconv.Itoa(ppid)).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// detectDialect picks the target dialect: an explicit override (--shell or
+// ASK_SHELL) wins, then the shell ask was launched from, then $SHELL.
+func detectDialect(override string) (shellDialect, error) {
+	if override != "" {
+		d, ok := parseDialect(override)
+		if !ok {
+			return "", fmt.Errorf("unsupported shell %q (want sh, bash, zsh, or fish)", override)
+		}
+		return d, nil
+	}
+	if d, ok := parseDialect(parentProcessName()); ok {
+		return d, nil
+	}
+	if d, ok := parseDialect(os.Getenv("SHELL")); ok {
+		return d, nil
+	}
+	return dialectSh, nil
+}
+
+// interpreter returns the program used to run commands in this dialect,
+// falling back to sh if it isn't installed.
+func (d shellDialect) interpreter() string {
+	if d == "" || d == dialectSh {
+		return "sh"
+	}
+	if _, err := exec.LookPath(string(d)); err != nil {
+		return "sh"
+	}
+	return string(d)
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDialect(t *testing.T) {
+	tests := []struct {
+		name string
+		want shellDialect
+		ok   bool
+	}{
+		{"bash", dialectBash, true},
+		{"/bin/zsh", dialectZsh, true},
+		{"/usr/local/bin/fish", dialectFish, true},
+		{"-zsh", dialectZsh, true},
+		{"dash", dialectSh, true},
+		{"/bin/sh", dialectSh, true},
+		{"tmux: server", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := parseDialect(tt.name)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseDialect(%q) = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDetectDialectOverride(t *testing.T) {
+	d, err := detectDialect("fish")
+	if err != nil || d != dialectFish {
+		t.Errorf("detectDialect(fish) = %q, %v; want fish", d, err)
+	}
+	if _, err := detectDialect("powershell"); err == nil {
+		t.Error("detectDialect(powershell) should fail")
+	}
+	if d, err := detectDialect(""); err != nil || d == "" {
+		t.Errorf("detectDialect(\"\") = %q, %v; want a dialect", d, err)
+	}
+}
+
+func TestDialectInterpreter(t *testing.T) {
+	if got := shellDialect("").interpreter(); got != "sh" {
+		t.Errorf("zero dialect interpreter = %q, want sh", got)
+	}
+	if got := dialectSh.interpreter(); got != "sh" {
+		t.Errorf("sh interpreter = %q, want sh", got)
+	}
+	// Falls back to sh when the shell isn't installed
+	t.Setenv("PATH", t.TempDir())
+	if got := dialectFish.interpreter(); got != "sh" {
+		t.Errorf("missing fish interpreter = %q, want sh", got)
+	}
+}
+
+func TestBuildPromptDialectRules(t *testing.T) {
+	defer func(d shellDialect) { targetDialect = d }(targetDialect)
+
+	targetDialect = dialectFish
+	prompt := buildPrompt("loop over txt files")
+	if !strings.Contains(prompt, "Shell dialect: fish") {
+		t.Error("buildPrompt() should name the fish dialect")
+	}
+	if !strings.Contains(prompt, "Use fish syntax") {
+		t.Error("buildPrompt() should include fish rules")
+	}
+
+	targetDialect = dialectBash
+	prompt = buildPrompt("loop over txt files")
+	if strings.Contains(prompt, "Use fish syntax") {
+		t.Error("bash prompt should not include fish rules")
+	}
+}
+
+func TestExecuteCommandUsesDialect(t *testing.T) {
+	defer func(d shellDialect) { targetDialect = d }(targetDialect)
+
+	targetDialect = dialectBash
+	if dialectBash.interpreter() != "bash" {
+		t.Skip("bash not installed")
+	}
+	stdout, _, err := executeCommand(`echo $BASH_VERSION`)
+	if err != nil || strings.TrimSpace(stdout) == "" {
+		t.Errorf("executeCommand() under bash = %q, %v; want a bash version", stdout, err)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// docsTimeout bounds each man or --help lookup.
+	docsTimeout = 3 * time.Second
+	// maxFlagDocLen caps one flag's description in the prompt.
+	maxFlagDocLen = 240
+)
+
+// lookupDocs returns the local documentation for a command and optional
+// subcommand. It is a variable so tests can stub it.
+var lookupDocs = localDocs
+
+var docsCache = make(map[string]string)
+
+var (
+	overstrikeRe = regexp.MustCompile(".\x08")
+	ansiRe       = regexp.MustCompile("\x1b\\[[0-9;]*m")
+	isWordRe     = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+)
+
+// localDocs returns the man page for "name-sub" (git-commit), or else the
+// man page or --help output of name, as plain text. It returns "" when none
+// is available.
+func localDocs(name, sub string) string {
+	if sub != "" && isWordRe.MatchString(sub) {
+		if doc := cachedDocs(name+"-"+sub, false); doc != "" {
+			return doc
+		}
+	}
+	return cachedDocs(name, true)
+}
+
+func cachedDocs(name string, tryHelp bool) string {
+	if doc, ok := docsCache[name]; ok {
+		return doc
+	}
+	// Only programs on PATH: never run a script named in the command.
+	if name == "" || strings.ContainsRune(name, '/') {
+		return ""
+	}
+	doc := runDocs("man", "-P", "cat", name)
+	if doc == "" && tryHelp {
+		if _, err := exec.LookPath(name); err == nil {
+			doc = runDocs(name, "--help")
+		}
+	}
+	docsCache[name] = doc
+	return doc
+}
+
+// runDocs runs a documentation command and returns its output without
+// terminal formatting, or "" if it failed.
+func runDocs(name string, args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), docsTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), "MANWIDTH=100", "MANPAGER=cat", "PAGER=cat")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out // usage often goes to stderr
+	if err := cmd.Run(); err != nil && out.Len() == 0 {
+		return ""
+	}
+	if ctx.Err() != nil {
+		return ""
+	}
+	return ansiRe.ReplaceAllString(overstrikeRe.ReplaceAllString(out.String(), ""), "")
+}
+
+// optionNames returns the option names in the header of an option entry:
+// "-a, --all" gives -a and --all, "--color[=WHEN]" gives --color.
+func optionNames(header string) []string {
+	var names []string
+	for _, field := range strings.FieldsFunc(header, func(r rune) bool { return r == ',' || r == ' ' || r == '|' }) {
+		if !strings.HasPrefix(field, "-") {
+			continue
+		}
+		if i := strings.IndexAny(field, "=[<"); i > 0 {
+			field = field[:i]
+		}
+		names = append(names, field)
+	}
+	return names
+}
+
+var optionGapRe = regexp.MustCompile(`\s{2,}|\t`)
+
+// findFlagDoc looks flag up in doc and returns the description of its
+// option entry, whether the option takes a value ("-w COLS",
+// "--width=COLS"), and whether it has an entry at all.
+func findFlagDoc(doc, flag string) (string, bool, bool) {
+	lines := strings.Split(doc, "\n")
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "-") {
+			continue
+		}
+		header, desc := trimmed, ""
+		if loc := optionGapRe.FindStringIndex(trimmed); loc != nil {
+			header, desc = trimmed[:loc[0]], trimmed[loc[1]:]
+		}
+		names := optionNames(header)
+		found := false
+		for _, name := range names {
+			if name == flag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			continue
+		}
+		takesValue := strings.ContainsAny(header, "=<") || len(strings.Fields(strings.ReplaceAll(header, ",", " "))) > len(names)
+
+		// the description continues on more deeply indented lines
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		parts := []string{desc}
+		for _, next := range lines[i+1 : min(i+4, len(lines))] {
+			nextTrimmed := strings.TrimSpace(next)
+			if nextTrimmed == "" || strings.HasPrefix(nextTrimmed, "-") || len(next)-len(strings.TrimLeft(next, " \t")) <= indent {
+				break
+			}
+			parts = append(parts, nextTrimmed)
+		}
+		desc = strings.Join(strings.Fields(strings.Join(parts, " ")), " ")
+		if len(desc) > maxFlagDocLen {
+			desc = desc[:maxFlagDocLen] + "..."
+		}
+		return desc, takesValue, true
+	}
+	return "", false, false
+}
+
+// documentFlag looks up a flag as written in a command. "--color=auto" is
+// looked up as --color, and combined short flags like -la as -l and -a.
+func documentFlag(doc, flag string) (string, bool) {
+	if doc == "" {
+		return "", false
+	}
+	if i := strings.Index(flag, "="); i > 0 {
+		flag = flag[:i]
+	}
+	if desc, _, ok := findFlagDoc(doc, flag); ok {
+		return desc, true
+	}
+	if strings.HasPrefix(flag, "--") || len(flag) <= 2 {
+		return "", false
+	}
+
+	var descs []string
+	for _, letter := range flag[1:] {
+		desc, takesValue, ok := findFlagDoc(doc, "-"+string(letter))
+		if !ok {
+			return "", false
+		}
+		descs = append(descs, fmt.Sprintf("-%c: %s", letter, desc))
+		if takesValue {
+			break // the rest is its value: -n5, -ofile
+		}
+	}
+	return strings.Join(descs, "; "), true
+}
+
+// groundFlags looks up every flag of stages in the local documentation of
+// the binary it belongs to. It marks flags without documentation as
+// unverified and returns the descriptions found, one per line, for the
+// prompt.
+func groundFlags(stages []explainStage) string {
+	var b strings.Builder
+	for i := range stages {
+		tokens := stages[i].Tokens
+		bin, doc := "", ""
+		for j := range tokens {
+			t := &tokens[j]
+			switch {
+			case t.Kind == "command" || t.Kind == "argument" && isWrappedCommand(bin, tokens, j):
+				bin = strings.Trim(t.Text, `'"`)
+				sub := ""
+				if j+1 < len(tokens) && tokens[j+1].Kind == "argument" {
+					sub = tokens[j+1].Text
+				}
+				doc = lookupDocs(bin, sub)
+			case t.Kind == "flag" && t.Text != "--":
+				desc, ok := documentFlag(doc, t.Text)
+				t.Unverified = !ok
+				if desc != "" {
+					fmt.Fprintf(&b, "%s %s: %s\n", bin, t.Text, desc)
+				}
+			}
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// wrapsCommand reports whether bin runs a command given as its arguments,
+// so the documentation of later flags is that command's.
+func wrapsCommand(bin string) bool {
+	_, ok := commandWrappers[bin]
+	return ok
+}
+
+// isWrappedCommand reports whether tokens[j] is the command run by the
+// wrapper bin, rather than one of the wrapper's own arguments: not an
+// environment assignment (env FOO=1), a number (timeout 5) or the value of
+// sudo -u/-g.
+func isWrappedCommand(bin string, tokens []explainToken, j int) bool {
+	text := tokens[j].Text
+	if !wrapsCommand(bin) || strings.Contains(text, "=") || text[0] >= '0' && text[0] <= '9' {
+		return false
+	}
+	if prev := tokens[j-1].Text; prev == "-u" || prev == "-g" {
+		return false
+	}
+	return true
+}
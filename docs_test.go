@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const gnuLsDoc = `LS(1)                     User Commands                     LS(1)
+
+OPTIONS
+       -a, --all
+              do not ignore entries starting with .
+
+       --color[=WHEN]
+              color the output WHEN; more info below
+
+       -l     use a long listing format
+
+       -w, --width=COLS
+              set output width to COLS.  0 means no limit
+`
+
+const bsdLsDoc = `LS(1)                 General Commands Manual                 LS(1)
+
+     -G      Enable colorized output.  This option is equivalent to
+             defining CLICOLOR or COLORTERM in the environment.
+     -l      (The lowercase letter "ell".) List files in the long format.
+`
+
+func TestDocumentFlag(t *testing.T) {
+	tests := []struct {
+		doc, flag, want string
+		ok              bool
+	}{
+		{gnuLsDoc, "-a", "do not ignore entries starting with .", true},
+		{gnuLsDoc, "--all", "do not ignore entries starting with .", true},
+		{gnuLsDoc, "--color=auto", "color the output WHEN; more info below", true},
+		{gnuLsDoc, "-l", "use a long listing format", true},
+		{gnuLsDoc, "-la", "-l: use a long listing format; -a: do not ignore entries starting with .", true},
+		{gnuLsDoc, "-w80", "-w: set output width to COLS. 0 means no limit", true},
+		{gnuLsDoc, "-G", "", false},
+		{gnuLsDoc, "--group-directories-first", "", false},
+		{bsdLsDoc, "-G", "Enable colorized output. This option is equivalent to defining CLICOLOR or COLORTERM in the environment.", true},
+		{bsdLsDoc, "--color", "", false},
+		{"", "-l", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := documentFlag(tt.doc, tt.flag)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("documentFlag(%s) = %q, %v; want %q, %v", tt.flag, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// stubDocs replaces the local documentation lookup for the test.
+func stubDocs(t *testing.T, docs map[string]string) {
+	t.Helper()
+	orig := lookupDocs
+	lookupDocs = func(name, sub string) string {
+		if doc, ok := docs[name+"-"+sub]; ok {
+			return doc
+		}
+		return docs[name]
+	}
+	t.Cleanup(func() { lookupDocs = orig })
+}
+
+func TestGroundFlags(t *testing.T) {
+	stubDocs(t, map[string]string{
+		"ls":         gnuLsDoc,
+		"sudo":       "  -u user, --user=user\n        run the command as user\n",
+		"git-commit": "       -m <msg>, --message=<msg>\n           Use the given <msg> as the commit message.\n",
+	})
+
+	stages, err := explainStages("sudo -u bob ls -lG --color=auto -- x && git commit -m fix && mystery -q")
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := groundFlags(stages)
+
+	unverified := map[string]bool{}
+	for _, s := range stages {
+		for _, tok := range s.Tokens {
+			if tok.Unverified {
+				unverified[tok.Text] = true
+			}
+		}
+	}
+	for flag, want := range map[string]bool{"-u": false, "-lG": true, "--color=auto": false, "--": false, "-m": false, "-q": true} {
+		if unverified[flag] != want {
+			t.Errorf("%s unverified = %v, want %v", flag, unverified[flag], want)
+		}
+	}
+	for _, want := range []string{"sudo -u: run the command as user", "ls --color=auto: color the output", "git -m: Use the given <msg> as the commit message."} {
+		if !strings.Contains(docs, want) {
+			t.Errorf("groundFlags() docs missing %q:\n%s", want, docs)
+		}
+	}
+}
@@ -4,46 +4,250 @@ import (
 	"fmt"
 	"os"
 	"regexp"
-	"runtime"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
+// explainToken is one token of an explained command.
+type explainToken struct {
+	Kind        string `json:"kind"` // command, flag, argument, redirection, assignment, keyword, group
+	Text        string `json:"text"`
+	Explanation string `json:"explanation"`
+	// Unverified marks a flag that the local man page or --help output
+	// doesn't document.
+	Unverified bool `json:"unverified,omitempty"`
+}
+
+// explainStage is one command of a pipeline or list.
+type explainStage struct {
+	Op      string         `json:"op,omitempty"` // how it joins the previous stage: "|", "&&", "||", ";", "&"
+	Command string         `json:"command"`
+	Tokens  []explainToken `json:"tokens"`
+}
+
+// commandExplanation is a command parsed into stages and tokens, with the
+// model's explanation of each token.
+type commandExplanation struct {
+	Summary string
+	Stages  []explainStage
+}
+
+// explainStages parses command into its stages and tokens.
+func explainStages(command string) ([]explainStage, error) {
+	list, err := parseShell(command)
+	if err != nil {
+		return nil, fmt.Errorf("can't parse command: %w", err)
+	}
+	stages := appendStages(nil, list, "")
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("nothing to explain")
+	}
+	return stages, nil
+}
+
+func appendStages(stages []explainStage, list *shellList, op string) []explainStage {
+	for _, pl := range list.Pipelines {
+		for i, c := range pl.Commands {
+			if i > 0 {
+				op = "|"
+			}
+			stages = append(stages, explainStage{Op: op, Command: c.Raw, Tokens: commandTokens(c)})
+			if c.Group != nil {
+				stages = appendStages(stages, c.Group, "")
+			}
+		}
+		op = pl.Op
+	}
+	return stages
+}
+
+func commandTokens(c *simpleCommand) []explainToken {
+	var tokens []explainToken
+	if c.Keyword != "" {
+		tokens = append(tokens, explainToken{Kind: "keyword", Text: c.Keyword})
+	}
+	switch {
+	case c.Func != "":
+		tokens = append(tokens, explainToken{Kind: "group", Text: c.Func + "() { ... }"})
+	case c.Subshell:
+		tokens = append(tokens, explainToken{Kind: "group", Text: "( ... )"})
+	case c.Group != nil:
+		tokens = append(tokens, explainToken{Kind: "group", Text: "{ ... }"})
+	}
+	for _, w := range c.Assigns {
+		tokens = append(tokens, explainToken{Kind: "assignment", Text: w.Raw})
+	}
+	for i, w := range c.Words {
+		kind := "argument"
+		if i == 0 {
+			kind = "command"
+		} else if !w.Quoted && strings.HasPrefix(w.Raw, "-") && w.Raw != "-" {
+			kind = "flag"
+		}
+		tokens = append(tokens, explainToken{Kind: kind, Text: w.Raw})
+	}
+	for _, r := range c.Redirects {
+		tokens = append(tokens, explainToken{Kind: "redirection", Text: r.String()})
+	}
+	return tokens
+}
+
+// formatTokens numbers the tokens of all stages for the prompt.
+func formatTokens(stages []explainStage) string {
+	var b strings.Builder
+	n := 0
+	for _, stage := range stages {
+		for _, t := range stage.Tokens {
+			n++
+			fmt.Fprintf(&b, "%d. %s (%s)\n", n, t.Text, t.Kind)
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
 func buildExplainPrompt(command string) string {
-	shell := os.Getenv("SHELL")
-	if shell == "" {
-		shell = "/bin/sh"
+	stages, _ := explainStages(command)
+	return buildStagesPrompt(command, stages)
+}
+
+// buildStagesPrompt builds the explain prompt for command, already parsed
+// into stages. It grounds the flags of stages in the local documentation,
+// marking the undocumented ones unverified.
+func buildStagesPrompt(command string, stages []explainStage) string {
+	data := newPromptData("")
+	data.Command = command
+	if len(stages) > 0 {
+		data.Tokens = formatTokens(stages)
+		data.Docs = groundFlags(stages)
 	}
-	osName := runtime.GOOS
+	return renderTemplateOrDefault(explainTemplateName, data)
+}
 
-	return fmt.Sprintf(`You are a shell command explainer. Given a command, output a short plain-text explanation.
+var tokenLineRe = regexp.MustCompile(`^(\d+)\s*[:.)]\s*(.*)$`)
 
-Operating system: %s
-Shell: %s
+// parseExplainResponse reads the "Summary:" line and the numbered token
+// explanations from the model's answer. Text that fits neither becomes the
+// summary if there is none.
+func parseExplainResponse(s string) (string, map[int]string) {
+	var summary string
+	var other []string
+	byToken := make(map[int]string)
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if plain := boldRe.ReplaceAllString(line, "$1"); strings.HasPrefix(strings.ToLower(plain), "summary:") {
+			summary = stripMarkdown(strings.TrimSpace(plain[len("summary:"):]))
+		} else if m := tokenLineRe.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			byToken[n] = stripMarkdown(m[2])
+		} else {
+			other = append(other, line)
+		}
+	}
+	if summary == "" {
+		summary = stripMarkdown(strings.Join(other, "\n"))
+	}
+	return summary, byToken
+}
 
-Output format rules:
-- Plain text only. No markdown, no bold, no backticks, no bullet points, no numbered lists, no headings.
-- First line: one sentence summarizing what the command does.
-- Following lines: one line per flag/argument, indented with two spaces.
-- Nothing else.
+// trimTokenEcho drops the token itself when the model repeats it before the
+// explanation ("-r: recurse" for token -r).
+func trimTokenEcho(explanation, token string) string {
+	for _, sep := range []string{":", " -", " —", " –"} {
+		if rest, ok := strings.CutPrefix(explanation, token+sep); ok {
+			return strings.TrimSpace(rest)
+		}
+	}
+	return explanation
+}
 
-Example input: grep -rn "TODO" src/
-Example output:
-Searches for the text "TODO" in all files under src/ recursively, showing line numbers.
-  -r: search recursively through directories
-  -n: show line numbers in output
-  "TODO": the pattern to search for
-  src/: the directory to search in
+func buildFreeformExplainPrompt(command string) string {
+	data := newPromptData("")
+	data.Command = command
+	return renderTemplateOrDefault(explainFreeTemplateName, data)
+}
 
-Command to explain: %s`, osName, shell, command)
+// explainCommand parses command locally and asks the model to explain each
+// of its tokens. A command that can't be parsed, like fish syntax, is
+// explained as a whole, without stages.
+func explainCommand(model, command string) (*commandExplanation, error) {
+	stages, err := explainStages(command)
+	if err != nil {
+		result, err := generate(model, buildFreeformExplainPrompt(command))
+		if err != nil {
+			return nil, err
+		}
+		return &commandExplanation{Summary: stripMarkdown(result), Stages: []explainStage{}}, nil
+	}
+	result, err := generate(model, buildStagesPrompt(command, stages))
+	if err != nil {
+		return nil, err
+	}
+
+	summary, byToken := parseExplainResponse(result)
+	n := 0
+	for i := range stages {
+		for j := range stages[i].Tokens {
+			n++
+			t := &stages[i].Tokens[j]
+			t.Explanation = trimTokenEcho(byToken[n], t.Text)
+		}
+	}
+	return &commandExplanation{Summary: summary, Stages: stages}, nil
+}
+
+// maxTokenColumn caps how wide the token column of the tree gets.
+const maxTokenColumn = 24
+
+// format renders the explanation as the summary followed by a tree of
+// tokens per stage, with the explanations aligned.
+func (e *commandExplanation) format(f *os.File) string {
+	width := 0
+	for _, stage := range e.Stages {
+		for _, t := range stage.Tokens {
+			width = max(width, min(utf8.RuneCountInString(t.Text), maxTokenColumn))
+		}
+	}
+
+	var b strings.Builder
+	if e.Summary != "" {
+		b.WriteString(e.Summary + "\n\n")
+	}
+	for _, stage := range e.Stages {
+		header := stage.Command
+		if stage.Op != "" {
+			header = stage.Op + " " + header
+		}
+		b.WriteString(paint(f, colorCyan, header) + "\n")
+		for i, t := range stage.Tokens {
+			branch := "├─ "
+			if i == len(stage.Tokens)-1 {
+				branch = "└─ "
+			}
+			line := branch + t.Text
+			if t.Explanation != "" || t.Unverified {
+				pad := max(width-utf8.RuneCountInString(t.Text), 0)
+				line += strings.Repeat(" ", pad) + "  " + t.Explanation
+			}
+			if t.Unverified {
+				line = strings.TrimRight(line, " ") + " " + paint(f, colorYellow, "(unverified)")
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
 }
 
+// explain returns the rendered explanation of command.
 func explain(model, command string) (string, error) {
-	prompt := buildExplainPrompt(command)
-	result, err := generate(model, prompt)
+	e, err := explainCommand(model, command)
 	if err != nil {
 		return "", err
 	}
-	return stripMarkdown(result), nil
+	return e.format(os.Stdout), nil
 }
 
 var (
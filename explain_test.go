@@ -1,6 +1,7 @@
 package main
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -102,3 +103,139 @@ func TestBuildExplainPromptDiffersFromBuildPrompt(t *testing.T) {
 		t.Error("translate prompt should not contain explain rules")
 	}
 }
+
+func TestExplainStages(t *testing.T) {
+	stages, err := explainStages(`FOO=1 find . -name '*.go' 2>/dev/null | xargs wc -l && (cd out; ls)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type stage struct{ op, kinds string }
+	var got []stage
+	for _, s := range stages {
+		var kinds []string
+		for _, tok := range s.Tokens {
+			kinds = append(kinds, tok.Kind+":"+tok.Text)
+		}
+		got = append(got, stage{s.Op, strings.Join(kinds, " ")})
+	}
+	want := []stage{
+		{"", "assignment:FOO=1 command:find argument:. flag:-name argument:'*.go' redirection:2>/dev/null"},
+		{"|", "command:xargs argument:wc flag:-l"},
+		{"&&", "group:( ... )"},
+		{"", "command:cd argument:out"},
+		{";", "command:ls"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("explainStages() =\n%v\nwant\n%v", got, want)
+	}
+
+	if _, err := explainStages(`echo "unterminated`); err == nil {
+		t.Error("explainStages() should fail on a command it can't parse")
+	}
+}
+
+func TestBuildExplainPromptTokens(t *testing.T) {
+	prompt := buildExplainPrompt("ls -la | wc -l")
+	if !strings.Contains(prompt, "1. ls (command)\n2. -la (flag)\n3. wc (command)\n4. -l (flag)") {
+		t.Errorf("buildExplainPrompt() should list the numbered tokens:\n%s", prompt)
+	}
+}
+
+func TestParseExplainResponse(t *testing.T) {
+	summary, tokens := parseExplainResponse("**Summary:** Lists files.\n1: `ls` lists a directory\n2. -la: long format, hidden files too\n\n3) counts lines")
+	if summary != "Lists files." {
+		t.Errorf("summary = %q", summary)
+	}
+	want := map[int]string{1: "ls lists a directory", 2: "-la: long format, hidden files too", 3: "counts lines"}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Errorf("tokens = %q, want %q", tokens, want)
+	}
+	if got := trimTokenEcho(tokens[2], "-la"); got != "long format, hidden files too" {
+		t.Errorf("trimTokenEcho() = %q", got)
+	}
+
+	summary, tokens = parseExplainResponse("Lists files in long format.")
+	if summary != "Lists files in long format." || len(tokens) != 0 {
+		t.Errorf("free-form response = %q, %v", summary, tokens)
+	}
+}
+
+func TestExplainCommandTree(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("NO_COLOR", "1")
+	stubDocs(t, map[string]string{
+		"find": "       -name pattern\n              Base of file name matches shell pattern pattern.\n",
+		"wc":   "       -l, --lines\n              print the newline counts\n",
+	})
+	fakeOllama(t, "Summary: Counts Go files.\n1: search for files\n2: start here\n3: match by name\n4: files ending in .go\n5: count input lines\n6: count lines only")
+
+	out, err := explain("test-model", "find . -name '*.go' | wc -l")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `Counts Go files.
+
+find . -name '*.go'
+├─ find    search for files
+├─ .       start here
+├─ -name   match by name
+└─ '*.go'  files ending in .go
+| wc -l
+├─ wc      count input lines
+└─ -l      count lines only`
+	if out != want {
+		t.Errorf("explain() =\n%s\nwant\n%s", out, want)
+	}
+
+	stubDocs(t, map[string]string{})
+	fakeOllama(t, "Summary: Lists files.\n1: list a directory\n2: made-up flag")
+	out, err = explain("test-model", "ls -Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "└─ -Z  made-up flag (unverified)") {
+		t.Errorf("an undocumented flag should be marked unverified:\n%s", out)
+	}
+}
+
+func TestExplainCommandUnparsable(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	stubDocs(t, map[string]string{})
+	fakeOllama(t, "Sets the variable FOO to the current directory.\n  -x: export it")
+
+	e, err := explainCommand("test-model", "set -x FOO (pwd)")
+	if err != nil {
+		t.Fatalf("fish syntax should fall back to a free-form explanation, got %v", err)
+	}
+	if !strings.HasPrefix(e.Summary, "Sets the variable FOO") || len(e.Stages) != 0 {
+		t.Errorf("explainCommand() = %+v", e)
+	}
+
+	fakeOllama(t, "Summary: Prints 0 to 2.\n1: loop three times\n2: print\n3: the counter")
+	e, err = explainCommand("test-model", "for ((i=0;i<3;i++)); do echo $i; done")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Summary != "Prints 0 to 2." || len(e.Stages) == 0 {
+		t.Errorf("explainCommand() = %+v", e)
+	}
+}
+
+func TestExplainCommandGroundsOnce(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	lookups := 0
+	orig := lookupDocs
+	lookupDocs = func(name, sub string) string {
+		lookups++
+		return ""
+	}
+	t.Cleanup(func() { lookupDocs = orig })
+	fakeOllama(t, "Summary: Lists files.\n1: list a directory\n2: long format")
+
+	if _, err := explainCommand("test-model", "ls -l"); err != nil {
+		t.Fatal(err)
+	}
+	if lookups != 1 {
+		t.Errorf("explainCommand looked up the docs %d times, want once", lookups)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// scriptBlock is a run of script lines explained together. Start and end are
+// 1-based line numbers.
+type scriptBlock struct {
+	start, end int
+	lines      []string
+}
+
+func (b scriptBlock) text() string {
+	return strings.Join(b.lines, "\n")
+}
+
+var (
+	// heredocRe captures the delimiter of a heredoc (<<EOF, <<-'EOF', <<"EOF").
+	heredocRe = regexp.MustCompile(`<<-?\s*['"]?([A-Za-z_][A-Za-z0-9_]*)['"]?`)
+	// commandSepRe splits a line into the commands on it.
+	commandSepRe = regexp.MustCompile(`;;|&&|\|\||[;&|()]`)
+)
+
+// blockDepth returns how much line changes the compound-command nesting depth:
+// if/case/for/while/until/select and { open a level, fi/esac/done and } close
+// one. Only words in command position count, so "echo done" doesn't close
+// anything. It is a heuristic meant to keep bodies in one block.
+func blockDepth(line string) int {
+	if i := strings.Index(line, " #"); i >= 0 {
+		line = line[:i]
+	}
+	if strings.HasPrefix(strings.TrimSpace(line), "#") {
+		return 0
+	}
+	depth := 0
+	for _, segment := range commandSepRe.Split(line, -1) {
+		words := strings.Fields(segment)
+		for len(words) > 0 && (words[0] == "then" || words[0] == "do" || words[0] == "else" || words[0] == "!") {
+			words = words[1:]
+		}
+		if len(words) == 0 {
+			continue
+		}
+		switch words[0] {
+		case "if", "case", "for", "while", "until", "select", "{":
+			depth++
+		case "fi", "esac", "done", "}":
+			depth--
+		}
+		if len(words) > 1 && words[len(words)-1] == "{" {
+			depth++ // function f {
+		}
+	}
+	return depth
+}
+
+// splitScriptBlocks splits a script into logical blocks at blank lines,
+// keeping compound commands, heredocs and continued lines together. Comments
+// directly above a block belong to it.
+func splitScriptBlocks(script string) []scriptBlock {
+	var blocks []scriptBlock
+	var cur scriptBlock
+	depth := 0
+	heredoc := ""
+	continued := false
+
+	flush := func() {
+		if len(cur.lines) > 0 {
+			blocks = append(blocks, cur)
+		}
+		cur = scriptBlock{}
+	}
+
+	for i, line := range strings.Split(strings.TrimRight(script, "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case heredoc != "":
+			if trimmed == heredoc {
+				heredoc = ""
+			}
+		case trimmed == "":
+			if depth <= 0 && !continued {
+				flush()
+				continue
+			}
+		default:
+			depth += blockDepth(line)
+			if m := heredocRe.FindStringSubmatch(line); m != nil {
+				heredoc = m[1]
+			}
+		}
+		continued = strings.HasSuffix(line, "\\")
+
+		if len(cur.lines) == 0 {
+			cur.start = i + 1
+		}
+		cur.lines = append(cur.lines, line)
+		cur.end = i + 1
+	}
+	flush()
+	return blocks
+}
+
+func buildExplainBlockPrompt(path string, block scriptBlock) string {
+	data := newPromptData("")
+	data.Command = block.text()
+	data.Request = fmt.Sprintf("lines %d-%d of %s", block.start, block.end, path)
+	return renderTemplateOrDefault(explainBlockTemplateName, data)
+}
+
+// explainFile explains a script block by block. Each block is listed with
+// its line numbers, dangerous lines highlighted, followed by its explanation.
+func explainFile(model, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	script := string(data)
+	blocks := splitScriptBlocks(script)
+	if len(blocks) == 0 {
+		return fmt.Errorf("%s is empty", path)
+	}
+
+	flagged := make(map[int][]compiledPattern)
+	for _, lw := range checkScript(script) {
+		flagged[lw.line] = append(flagged[lw.line], lw.warning)
+	}
+
+	for i, block := range blocks {
+		if i > 0 {
+			fmt.Println()
+		}
+		printScriptBlock(block, flagged)
+
+		spinner := NewSpinner("Explaining...")
+		spinner.Start()
+		result, err := generate(model, buildExplainBlockPrompt(path, block))
+		spinner.Stop()
+		if err != nil {
+			return err
+		}
+		fmt.Println(stripMarkdown(result))
+	}
+	return nil
+}
+
+// printScriptBlock prints block with line numbers, painting lines that have
+// danger warnings and listing the warnings below them.
+func printScriptBlock(block scriptBlock, flagged map[int][]compiledPattern) {
+	fmt.Println(paint(os.Stdout, colorCyan, fmt.Sprintf("── lines %d-%d ──", block.start, block.end)))
+	for i, line := range block.lines {
+		n := block.start + i
+		warnings := flagged[n]
+		text := fmt.Sprintf("%4d  %s", n, line)
+		if len(warnings) == 0 {
+			fmt.Println(text)
+			continue
+		}
+		color := colorYellow
+		for _, w := range warnings {
+			if w.severity == "high" {
+				color = colorRed
+			}
+		}
+		fmt.Println(paint(os.Stdout, color, text))
+		for _, w := range warnings {
+			fmt.Println(paint(os.Stdout, color, "      ⚠ Warning: "+w.message))
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func blockRanges(blocks []scriptBlock) [][2]int {
+	var ranges [][2]int
+	for _, b := range blocks {
+		ranges = append(ranges, [2]int{b.start, b.end})
+	}
+	return ranges
+}
+
+func TestSplitScriptBlocks(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   [][2]int
+	}{
+		{
+			name:   "blank lines separate blocks",
+			script: "#!/usr/bin/env bash\nset -e\n\n# build\nmake\n\n\nmake install\n",
+			want:   [][2]int{{1, 2}, {4, 5}, {8, 8}},
+		},
+		{
+			name:   "function body with blank line",
+			script: "deploy() {\n  build\n\n  upload\n}\n\ndeploy",
+			want:   [][2]int{{1, 5}, {7, 7}},
+		},
+		{
+			name:   "loop and if",
+			script: "for f in *.log; do\n\n  echo done\n\ndone\nif [ -f x ]; then\n\n  rm x\nfi",
+			want:   [][2]int{{1, 9}},
+		},
+		{
+			name:   "heredoc with blank line",
+			script: "cat <<'EOF' > conf\na=1\n\nb=2\nEOF\n\necho ok",
+			want:   [][2]int{{1, 5}, {7, 7}},
+		},
+		{
+			name:   "continued line",
+			script: "docker run \\\n\n  image\n\nls",
+			want:   [][2]int{{1, 3}, {5, 5}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := blockRanges(splitScriptBlocks(tt.script)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitScriptBlocks() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildExplainBlockPrompt(t *testing.T) {
+	block := scriptBlock{start: 4, end: 5, lines: []string{"# build", "make"}}
+	prompt := buildExplainBlockPrompt("deploy.sh", block)
+	for _, want := range []string{"lines 4-5 of deploy.sh", "# build\nmake"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("buildExplainBlockPrompt() missing %q", want)
+		}
+	}
+}
+
+func TestExplainFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	withPipe(t, &os.Stderr)
+	fakeOllama(t, "Sets up the script.", "**Deletes** the build directory.")
+
+	path := filepath.Join(t.TempDir(), "deploy.sh")
+	os.WriteFile(path, []byte("#!/bin/sh\nset -e\n\nrm -rf build/\n"), 0644)
+
+	var err error
+	out := captureStdout(t, func() { err = explainFile("test-model", path) })
+	if err != nil {
+		t.Fatalf("explainFile() error: %v", err)
+	}
+	for _, want := range []string{"── lines 1-2 ──", "Sets up the script.", "   4  rm -rf build/", "Warning: Recursive file deletion", "Deletes the build directory."} {
+		if !strings.Contains(out, want) {
+			t.Errorf("explainFile() output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Index(out, "Warning") < strings.Index(out, "lines 4-4") {
+		t.Error("the warning should be listed under its line in the second block")
+	}
+
+	if err := explainFile("test-model", filepath.Join(t.TempDir(), "missing.sh")); err == nil {
+		t.Error("explainFile() on a missing file should fail")
+	}
+}
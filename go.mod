@@ -2,6 +2,9 @@ module github.com/ykushch/ask
 
 go 1.21
 
-require github.com/chzyer/readline v1.5.1
+require (
+	github.com/chzyer/readline v1.5.1
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require golang.org/x/sys v0.0.0-20220310020820-b874c991c1a5 // indirect
@@ -56,6 +56,31 @@ func runInteractive(model string, stats *Stats) {
 			fmt.Printf("current model: %s\n", model)
 			continue
 		}
+		if input == "!undo" {
+			if err := runUndo(); err != nil {
+				fmt.Fprintln(os.Stderr, paint(os.Stderr, colorRed, fmt.Sprintf("error: %v", err)))
+			}
+			continue
+		}
+		if strings.HasPrefix(input, "!plan ") {
+			request := strings.TrimSpace(input[6:])
+			if request == "" {
+				fmt.Println("Usage: !plan <request>")
+				continue
+			}
+			spinner := NewSpinner("Planning...")
+			spinner.Start()
+			steps, err := generatePlan(model, request)
+			spinner.Stop()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, paint(os.Stderr, colorRed, fmt.Sprintf("error: %v", err)))
+				continue
+			}
+			stats.RecordPlan(model, request, steps)
+			runPlan(request, steps, stats)
+			lastCommand = steps[len(steps)-1]
+			continue
+		}
 		if strings.HasPrefix(input, "!explain ") {
 			cmd := strings.TrimSpace(input[9:])
 			if cmd == "" {
@@ -68,7 +93,7 @@ func runInteractive(model string, stats *Stats) {
 			explanation, err := explain(model, cmd)
 			spinner.Stop()
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "\033[31merror: %v\033[0m\n", err)
+				fmt.Fprintln(os.Stderr, paint(os.Stderr, colorRed, fmt.Sprintf("error: %v", err)))
 				continue
 			}
 			fmt.Println(explanation)
@@ -79,15 +104,22 @@ func runInteractive(model string, stats *Stats) {
 			if cmd == "" {
 				continue
 			}
-			warnIfDangerous(cmd)
-			stdout, stderr, _ := executeCommand(cmd)
-			if stdout != "" {
-				fmt.Print(stdout)
-			}
-			if stderr != "" {
-				fmt.Fprint(os.Stderr, stderr)
+			outcome := guardCommand(cmd, nil)
+			if !outcome.allowed {
+				continue
 			}
-			addToHistory(cmd, stdout+stderr)
+			snapshotBeforeRun(cmd, outcome.blast)
+			runAudited("", cmd, outcome, func() error {
+				stdout, stderr, err := executeCommand(cmd)
+				if stdout != "" {
+					fmt.Print(stdout)
+				}
+				if stderr != "" {
+					fmt.Fprint(os.Stderr, stderr)
+				}
+				addToHistory(cmd, stdout+stderr)
+				return err
+			})
 			lastCommand = cmd
 			continue
 		}
@@ -104,12 +136,24 @@ func runInteractive(model string, stats *Stats) {
 			explanation, err := explain(model, lastCommand)
 			spinner.Stop()
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "\033[31merror: %v\033[0m\n", err)
+				fmt.Fprintln(os.Stderr, paint(os.Stderr, colorRed, fmt.Sprintf("error: %v", err)))
 				continue
 			}
 			fmt.Println(explanation)
 			continue
 		}
+		if strings.HasPrefix(input, "?@") {
+			path := expandHome(strings.TrimSpace(input[2:]))
+			if path == "" {
+				fmt.Println("Usage: ?@FILE")
+				continue
+			}
+			stats.RecordExplain(model)
+			if err := explainFile(model, path); err != nil {
+				fmt.Fprintln(os.Stderr, paint(os.Stderr, colorRed, fmt.Sprintf("error: %v", err)))
+			}
+			continue
+		}
 		if strings.HasPrefix(input, "?") {
 			cmd := strings.TrimSpace(input[1:])
 			if cmd == "" {
@@ -121,7 +165,7 @@ func runInteractive(model string, stats *Stats) {
 			explanation, err := explain(model, cmd)
 			spinner.Stop()
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "\033[31merror: %v\033[0m\n", err)
+				fmt.Fprintln(os.Stderr, paint(os.Stderr, colorRed, fmt.Sprintf("error: %v", err)))
 				continue
 			}
 			fmt.Println(explanation)
@@ -144,15 +188,22 @@ func runInteractive(model string, stats *Stats) {
 
 		// Direct shell command (not natural language)
 		if !isNaturalLanguage(input) {
-			warnIfDangerous(input)
-			stdout, stderr, _ := executeCommand(input)
-			if stdout != "" {
-				fmt.Print(stdout)
-			}
-			if stderr != "" {
-				fmt.Fprint(os.Stderr, stderr)
+			outcome := guardCommand(input, nil)
+			if !outcome.allowed {
+				continue
 			}
-			addToHistory(input, stdout+stderr)
+			snapshotBeforeRun(input, outcome.blast)
+			runAudited("", input, outcome, func() error {
+				stdout, stderr, err := executeCommand(input)
+				if stdout != "" {
+					fmt.Print(stdout)
+				}
+				if stderr != "" {
+					fmt.Fprint(os.Stderr, stderr)
+				}
+				addToHistory(input, stdout+stderr)
+				return err
+			})
 			lastCommand = input
 			continue
 		}
@@ -163,11 +214,11 @@ func runInteractive(model string, stats *Stats) {
 		command, err := translate(model, input)
 		spinner.Stop()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "\033[31merror: %v\033[0m\n", err)
+			fmt.Fprintln(os.Stderr, paint(os.Stderr, colorRed, fmt.Sprintf("error: %v", err)))
 			continue
 		}
 		stats.RecordInteractiveCommand(model, input, command)
-		confirmAndRun(command, stats)
+		confirmAndRun(model, input, command, stats)
 		lastCommand = command
 	}
 }
@@ -175,7 +226,7 @@ func runInteractive(model string, stats *Stats) {
 func buildInteractivePrompt() string {
 	cwd, _ := os.Getwd()
 	dir := filepath.Base(cwd)
-	return fmt.Sprintf("\033[32m%s\033[0m > ", dir)
+	return paint(os.Stdout, colorGreen, dir) + " > "
 }
 
 func printHelp() {
@@ -183,8 +234,11 @@ func printHelp() {
 	fmt.Println("  !model NAME  — switch Ollama model")
 	fmt.Println("  !model       — show current model")
 	fmt.Println("  !explain CMD — explain a shell command")
+	fmt.Println("  !plan REQ    — plan a multi-step task and run it step by step")
+	fmt.Println("  !undo        — restore the files of the last snapshotted command")
 	fmt.Println("  ?CMD         — explain a shell command (shorthand)")
 	fmt.Println("  ?            — explain the last executed command")
+	fmt.Println("  ?@FILE       — explain a shell script block by block")
 	fmt.Println("  !cmd         — run cmd directly (bypass AI)")
 	fmt.Println("  Ctrl+D       — exit")
 	fmt.Println()
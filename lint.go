@@ -0,0 +1,225 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// severityRank orders the danger severities for --fail-on.
+var severityRank = map[string]int{"medium": 1, "high": 2}
+
+// stdinName is the file name findings in standard input are reported under.
+const stdinName = "<stdin>"
+
+// lintFinding is one danger warning in a linted file.
+type lintFinding struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Command  string `json:"command"`
+	Rule     string `json:"rule"` // where the rule is defined
+}
+
+// lintScript runs the danger checks over script, read from file.
+func lintScript(file, script string) []lintFinding {
+	var findings []lintFinding
+	for _, lw := range checkScript(script) {
+		findings = append(findings, lintFinding{
+			File:     file,
+			Line:     lw.line,
+			Severity: lw.warning.severity,
+			Message:  lw.warning.message,
+			Command:  lw.command,
+			Rule:     lw.warning.location(),
+		})
+	}
+	return findings
+}
+
+// lintFailed reports whether any finding is at or above the failOn severity.
+// "never" never fails.
+func lintFailed(findings []lintFinding, failOn string) bool {
+	if failOn == "never" {
+		return false
+	}
+	for _, f := range findings {
+		if severityRank[f.Severity] >= severityRank[failOn] {
+			return true
+		}
+	}
+	return false
+}
+
+func printLintFindings(w io.Writer, findings []lintFinding) {
+	for _, f := range findings {
+		color := colorYellow
+		if f.Severity == "high" {
+			color = colorRed
+		}
+		fmt.Fprintf(w, "%s:%d: %s\n", f.File, f.Line, paint(os.Stdout, color, f.Severity+": "+f.Message))
+		fmt.Fprintf(w, "    %s\n", f.Command)
+	}
+}
+
+var ruleIDRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// lintRuleID derives a stable SARIF rule id from a warning message.
+func lintRuleID(message string) string {
+	return "ask/" + strings.Trim(ruleIDRe.ReplaceAllString(strings.ToLower(message), "-"), "-")
+}
+
+// sarifLevel maps a severity onto a SARIF result level.
+func sarifLevel(severity string) string {
+	if severity == "high" {
+		return "error"
+	}
+	return "warning"
+}
+
+type (
+	sarifLog struct {
+		Version string     `json:"version"`
+		Schema  string     `json:"$schema"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		Version        string      `json:"version"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID                   string       `json:"id"`
+		ShortDescription     sarifMessage `json:"shortDescription"`
+		DefaultConfiguration struct {
+			Level string `json:"level"`
+		} `json:"defaultConfiguration"`
+	}
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		PhysicalLocation struct {
+			ArtifactLocation struct {
+				URI string `json:"uri"`
+			} `json:"artifactLocation"`
+			Region struct {
+				StartLine int `json:"startLine"`
+			} `json:"region"`
+		} `json:"physicalLocation"`
+	}
+)
+
+// newSarifLog converts findings into a SARIF 2.1.0 log, the format code
+// scanning tools such as GitHub's upload.
+func newSarifLog(findings []lintFinding) sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "ask",
+			Version:        version,
+			InformationURI: "https://github.com/ykushch/ask",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+	seen := make(map[string]bool)
+	for _, f := range findings {
+		id := lintRuleID(f.Message)
+		if !seen[id] {
+			seen[id] = true
+			rule := sarifRule{ID: id, ShortDescription: sarifMessage{Text: f.Message}}
+			rule.DefaultConfiguration.Level = sarifLevel(f.Severity)
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+		}
+		var loc sarifLocation
+		loc.PhysicalLocation.ArtifactLocation.URI = f.File
+		loc.PhysicalLocation.Region.StartLine = f.Line
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    id,
+			Level:     sarifLevel(f.Severity),
+			Message:   sarifMessage{Text: fmt.Sprintf("%s: %s", f.Message, f.Command)},
+			Locations: []sarifLocation{loc},
+		})
+	}
+	return sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs:    []sarifRun{run},
+	}
+}
+
+// runLintCommand implements "ask lint [files]": it runs the danger checks
+// over each file, or standard input with no files or "-", and prints the
+// findings. It reports whether any finding is at or above --fail-on.
+func runLintCommand(args []string) (bool, error) {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	format := flags.String("format", "text", "Output format: text, json or sarif")
+	failOn := flags.String("fail-on", "medium", "Exit with status 1 on findings of this `severity` or above: medium, high or never")
+	if err := flags.Parse(args); err != nil {
+		return false, err
+	}
+	if *failOn != "never" && severityRank[*failOn] == 0 {
+		return false, fmt.Errorf("unknown --fail-on %q (want medium, high or never)", *failOn)
+	}
+	if *format != "text" && *format != "json" && *format != "sarif" {
+		return false, fmt.Errorf("unknown format %q (want text, json or sarif)", *format)
+	}
+
+	staticChecks = true
+	defer func() { staticChecks = false }()
+
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	findings := []lintFinding{}
+	for _, file := range files {
+		var data []byte
+		var err error
+		if file == "-" {
+			file = stdinName
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(file)
+		}
+		if err != nil {
+			return false, err
+		}
+		findings = append(findings, lintScript(file, string(data))...)
+	}
+
+	switch *format {
+	case "json":
+		printJSON(findings)
+	case "sarif":
+		printJSON(newSarifLog(findings))
+	default:
+		printLintFindings(os.Stdout, findings)
+		if len(findings) > 0 {
+			counted := make(map[string]bool)
+			for _, f := range findings {
+				counted[f.File] = true
+			}
+			fmt.Fprintf(os.Stderr, "%s in %s\n", plural(len(findings), "finding"), plural(len(counted), "file"))
+		}
+	}
+	return lintFailed(findings, *failOn), nil
+}
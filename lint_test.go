@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunLintCommand(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("NO_COLOR", "1")
+	dir := t.TempDir()
+	deploy := filepath.Join(dir, "deploy.sh")
+	os.WriteFile(deploy, []byte("#!/bin/sh\nset -e\n\nrm -r build\n"), 0644)
+	clean := filepath.Join(dir, "clean.sh")
+	os.WriteFile(clean, []byte("#!/bin/sh\nls -la\n"), 0644)
+	withPipe(t, &os.Stderr)
+
+	var failed bool
+	var err error
+	out := captureStdout(t, func() { failed, err = runLintCommand([]string{deploy, clean}) })
+	if err != nil || !failed {
+		t.Errorf("runLintCommand() = %v, %v, want a failure", failed, err)
+	}
+	if want := deploy + ":4: medium: "; !strings.Contains(out, want) {
+		t.Errorf("output missing %q:\n%s", want, out)
+	}
+
+	captureStdout(t, func() { failed, err = runLintCommand([]string{"--fail-on", "high", deploy}) })
+	if err != nil || failed {
+		t.Errorf("a medium finding should pass --fail-on high, got %v, %v", failed, err)
+	}
+
+	out = captureStdout(t, func() { failed, err = runLintCommand([]string{"--format", "json", clean}) })
+	if err != nil || failed || strings.TrimSpace(out) != "[]" {
+		t.Errorf("a clean file should print [] and pass, got %q, %v, %v", out, failed, err)
+	}
+
+	if _, err := runLintCommand([]string{"--fail-on", "low", clean}); err == nil {
+		t.Error("an unknown --fail-on should be an error")
+	}
+	if _, err := runLintCommand([]string{filepath.Join(dir, "missing.sh")}); err == nil {
+		t.Error("a missing file should be an error")
+	}
+}
+
+func TestRunLintCommandIgnoresFilesystem(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := blastDir(t, map[string]string{"config.json": `{"debug": true}`})
+	script := filepath.Join(dir, "setup.sh")
+	os.WriteFile(script, []byte("#!/bin/sh\necho \"{}\" > config.json\n"), 0644)
+	withPipe(t, &os.Stderr)
+
+	var failed bool
+	var err error
+	out := captureStdout(t, func() { failed, err = runLintCommand([]string{"--format", "json", script}) })
+	if err != nil || failed || strings.TrimSpace(out) != "[]" {
+		t.Errorf("lint should not flag a redirect onto a file where it runs, got %q, %v, %v", out, failed, err)
+	}
+	if len(checkDangerousCommand(`echo "{}" > config.json`)) != 1 {
+		t.Error("outside lint, overwriting config.json should still be flagged")
+	}
+}
+
+func TestNewSarifLog(t *testing.T) {
+	findings := []lintFinding{
+		{File: "a.sh", Line: 3, Severity: "high", Message: "Recursive deletion of root", Command: "rm -rf /"},
+		{File: "b.sh", Line: 7, Severity: "high", Message: "Recursive deletion of root", Command: "sudo rm -rf /"},
+		{File: "b.sh", Line: 9, Severity: "medium", Message: "Force push (can overwrite remote history)", Command: "git push -f"},
+	}
+	data, err := json.Marshal(newSarifLog(findings))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine int `json:"startLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		t.Fatal(err)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 {
+		t.Fatalf("sarif log = %s", data)
+	}
+	run := log.Runs[0]
+	if len(run.Tool.Driver.Rules) != 2 || run.Tool.Driver.Rules[0].ID != "ask/recursive-deletion-of-root" {
+		t.Errorf("rules = %+v, want one per distinct warning", run.Tool.Driver.Rules)
+	}
+	if len(run.Results) != 3 {
+		t.Fatalf("got %d results, want 3", len(run.Results))
+	}
+	last := run.Results[2]
+	loc := last.Locations[0].PhysicalLocation
+	if last.RuleID != "ask/force-push-can-overwrite-remote-history" || last.Level != "warning" ||
+		loc.ArtifactLocation.URI != "b.sh" || loc.Region.StartLine != 9 {
+		t.Errorf("last result = %+v", last)
+	}
+}
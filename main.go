@@ -33,10 +33,32 @@ func main() {
 	flag.BoolVar(&doUpdate, "update", false, "Update ask to the latest version")
 	var doExplain bool
 	flag.BoolVar(&doExplain, "explain", false, "Explain a shell command instead of generating one")
+	explainPath := flag.String("explain-file", "", "Explain a shell script `file` block by block")
+	shellOverride := flag.String("shell", os.Getenv("ASK_SHELL"), "Shell dialect to generate and run commands in (sh, bash, zsh, fish)")
+	flag.BoolVar(&useUserShell, "user-shell", os.Getenv("ASK_USER_SHELL") != "", "Run commands in an interactive shell so your aliases and functions work")
+	var printOnly bool
+	flag.BoolVar(&printOnly, "print", false, "Print the generated command to stdout instead of running it")
+	var jsonOutput bool
+	flag.BoolVar(&jsonOutput, "json", false, "Print the generated command, warnings and metadata as JSON instead of running it")
+	flag.BoolVar(&assumeYes, "yes", false, "Run the generated command without asking for confirmation")
+	flag.BoolVar(&assumeYes, "y", false, "Shorthand for --yes")
+	flag.BoolVar(&forcePolicy, "force", false, "Override danger policies: run blocked commands, and skip typed confirmation with --yes")
+	flag.BoolVar(&assessRisk, "assess", os.Getenv("ASK_ASSESS") != "", "Ask the model for a second opinion on each generated command's risk before running it")
+	flag.BoolVar(&snapshotFiles, "undo", os.Getenv("ASK_UNDO") != "", "Snapshot files before destructive commands so `ask undo` can restore them")
+	var doPlan bool
+	flag.BoolVar(&doPlan, "plan", false, "Break the request into steps and run them one at a time")
+	scriptPath := flag.String("script", "", "Write a reusable bash script for the request to `file` instead of running a command")
 	var doStats bool
 	flag.BoolVar(&doStats, "stats", false, "Show usage statistics")
 	flag.Parse()
 
+	dialect, err := detectDialect(*shellOverride)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
+	targetDialect = dialect
+
 	if doUpdate {
 		if err := selfUpdate(); err != nil {
 			fmt.Fprintf(os.Stderr, "update failed: %v\n", err)
@@ -48,6 +70,7 @@ func main() {
 	if showVersion {
 		fmt.Printf("ask version %s\n", version)
 		fmt.Printf("model: %s\n", *model)
+		fmt.Printf("shell: %s\n", targetDialect)
 		fmt.Printf("ollama: %s\n", ollamaHost())
 		return
 	}
@@ -60,6 +83,60 @@ func main() {
 		return
 	}
 
+	args := flag.Args()
+
+	if len(args) > 1 && args[0] == "prompt" && args[1] == "show" {
+		if err := runPromptCommand(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) == 2 && args[0] == "init" {
+		if err := runInitCommand(args[1]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "rules" {
+		if err := runRulesCommand(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "undo" {
+		if err := runUndoCommand(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "lint" {
+		failed, err := runLintCommand(args[1:])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(2)
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(args) > 0 && args[0] == "audit" {
+		if err := runAuditCommand(args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Load stats for tracking
 	stats, _ := LoadStats()
 	stats.RecordInvocation()
@@ -70,12 +147,35 @@ func main() {
 	go backgroundVersionCheck(updateCh)
 
 	if err := checkOllama(); err != nil {
-		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		exitWithError(err, jsonOutput)
 	}
 
-	args := flag.Args()
-	if len(args) == 0 {
+	if len(args) == 1 && args[0] == "why" {
+		if err := runWhy(*model, stats); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		printUpdateNotice(updateCh)
+		return
+	}
+
+	if *explainPath != "" {
+		stats.RecordExplain(*model)
+		if err := explainFile(*model, *explainPath); err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(1)
+		}
+		printUpdateNotice(updateCh)
+		return
+	}
+
+	// Without a query, piped input is diagnosed.
+	piped := !isTerminal(os.Stdin)
+	if len(args) == 0 && !piped && (printOnly || jsonOutput) {
+		exitWithError(fmt.Errorf("--print and --json need a query"), jsonOutput)
+	}
+
+	if len(args) == 0 && !piped {
 		stats.RecordInteractiveSession()
 		runInteractive(*model, stats)
 		printUpdateNotice(updateCh)
@@ -85,21 +185,109 @@ func main() {
 	// One-shot mode: join all args as the query
 	query := strings.Join(args, " ")
 
+	if doExplain && query == "" {
+		exitWithError(fmt.Errorf("--explain needs a command"), jsonOutput)
+	}
+
 	if doExplain {
 		stats.RecordExplain(*model)
 		spinner := NewSpinner("Explaining...")
 		spinner.Start()
-		explanation, err := explain(*model, query)
+		explanation, err := explainCommand(*model, query)
 		spinner.Stop()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			os.Exit(1)
+			exitWithError(err, jsonOutput)
+		}
+		if jsonOutput {
+			printJSON(explainResult{Command: query, Model: *model, Summary: explanation.Summary, Stages: explanation.Stages})
+			return
+		}
+		fmt.Println(explanation.format(os.Stdout))
+		printUpdateNotice(updateCh)
+		return
+	}
+
+	if piped {
+		data, truncated, err := readPipedInput(os.Stdin, maxPipedBytes)
+		if err != nil {
+			exitWithError(fmt.Errorf("reading stdin: %w", err), jsonOutput)
+		}
+		if truncated {
+			fmt.Fprintf(os.Stderr, "warning: piped input truncated to %d MB\n", maxPipedBytes/(1024*1024))
+		}
+		if len(data) > 0 {
+			pipedInput = data
+		}
+	}
+
+	if pipedInput == nil && query == "" {
+		exitWithError(fmt.Errorf("no query and nothing piped in"), jsonOutput)
+	}
+
+	if pipedInput != nil && wantsDiagnosis(query, pipedInput) {
+		stats.RecordExplain(*model)
+		spinner := NewSpinner("Diagnosing...")
+		spinner.Start()
+		diagnosis, err := diagnose(*model, query, pipedInput)
+		spinner.Stop()
+		if err != nil {
+			exitWithError(err, jsonOutput)
+		}
+		if jsonOutput {
+			printJSON(diagnosisResult{Query: query, Model: *model, Diagnosis: diagnosis})
+			return
+		}
+		fmt.Println(diagnosis)
+		if !printOnly {
+			printUpdateNotice(updateCh)
+		}
+		return
+	}
+
+	if doPlan {
+		spinner := NewSpinner("Planning...")
+		spinner.Start()
+		steps, err := generatePlan(*model, query)
+		spinner.Stop()
+		if err != nil {
+			exitWithError(err, jsonOutput)
+		}
+		stats.RecordPlan(*model, query, steps)
+		switch {
+		case jsonOutput:
+			printJSON(newPlanResult(query, *model, steps))
+		case printOnly:
+			fmt.Println(strings.Join(steps, "\n"))
+		default:
+			runPlan(query, steps, stats)
+			printUpdateNotice(updateCh)
+		}
+		return
+	}
+
+	if *scriptPath != "" {
+		spinner := NewSpinner("Writing script...")
+		spinner.Start()
+		script, err := generateScript(*model, query)
+		spinner.Stop()
+		if err != nil {
+			exitWithError(err, jsonOutput)
+		}
+		stats.RecordScript(*model, query, script)
+		if err := writeScript(*scriptPath, script); err != nil {
+			exitWithError(err, jsonOutput)
 		}
-		fmt.Println(explanation)
 		printUpdateNotice(updateCh)
 		return
 	}
 
+	if printOnly || jsonOutput {
+		if err := generateForScript(*model, query, jsonOutput, stats); err != nil {
+			exitWithError(err, jsonOutput)
+		}
+		return
+	}
+
 	spinner := NewSpinner("Thinking...")
 	spinner.Start()
 	command, err := translate(*model, query)
@@ -109,7 +297,7 @@ func main() {
 		os.Exit(1)
 	}
 	stats.RecordOneshotCommand(*model, query, command)
-	confirmAndRun(command, stats)
+	confirmAndRun(*model, query, command, stats)
 	printUpdateNotice(updateCh)
 }
 
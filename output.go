@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// warningJSON is a danger warning in machine-readable output.
+type warningJSON struct {
+	Message  string `json:"message"`
+	Severity string `json:"severity"`
+}
+
+// commandResult is the --json output for a generated command.
+type commandResult struct {
+	Query     string        `json:"query"`
+	Command   string        `json:"command"`
+	Model     string        `json:"model"`
+	Shell     string        `json:"shell"`
+	Warnings  []warningJSON `json:"warnings"`
+	Project   []ProjectType `json:"project"`
+	LatencyMS int64         `json:"latency_ms"`
+}
+
+// explainResult is the --explain --json output.
+type explainResult struct {
+	Command string         `json:"command"`
+	Model   string         `json:"model"`
+	Summary string         `json:"summary"`
+	Stages  []explainStage `json:"stages"`
+}
+
+// diagnosisResult is the --json output when piped error output is diagnosed.
+type diagnosisResult struct {
+	Query     string `json:"query"`
+	Model     string `json:"model"`
+	Diagnosis string `json:"diagnosis"`
+}
+
+// planResult is the --plan --json output.
+type planResult struct {
+	Query string     `json:"query"`
+	Model string     `json:"model"`
+	Steps []planStep `json:"steps"`
+}
+
+type planStep struct {
+	Command  string        `json:"command"`
+	Warnings []warningJSON `json:"warnings"`
+}
+
+func newPlanResult(query, model string, steps []string) planResult {
+	result := planResult{Query: query, Model: model}
+	for _, step := range steps {
+		result.Steps = append(result.Steps, planStep{
+			Command:  step,
+			Warnings: toWarningJSON(checkDangerousCommand(step)),
+		})
+	}
+	return result
+}
+
+func toWarningJSON(warnings []compiledPattern) []warningJSON {
+	out := []warningJSON{}
+	for _, w := range warnings {
+		out = append(out, warningJSON{Message: w.message, Severity: w.severity})
+	}
+	return out
+}
+
+// printJSON writes v to stdout as indented JSON.
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// exitWithError reports err and exits with status 1, as a JSON object on
+// stdout when asJSON is set so callers can always parse the output.
+func exitWithError(err error, asJSON bool) {
+	if asJSON {
+		printJSON(map[string]string{"error": err.Error()})
+	} else {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	}
+	os.Exit(1)
+}
+
+// generateForScript translates query without any interactive output: with
+// asJSON it prints a commandResult, otherwise only the command itself.
+// Nothing is executed.
+func generateForScript(model, query string, asJSON bool, stats *Stats) error {
+	start := time.Now()
+	command, err := translate(model, query)
+	if err != nil {
+		return err
+	}
+	latency := time.Since(start)
+	stats.RecordOneshotCommand(model, query, command)
+
+	if !asJSON {
+		fmt.Println(command)
+		return nil
+	}
+
+	cwd, _ := os.Getwd()
+	project := detectProjects(cwd)
+	if project == nil {
+		project = []ProjectType{}
+	}
+	return printJSON(commandResult{
+		Query:     query,
+		Command:   command,
+		Model:     model,
+		Shell:     string(targetDialect),
+		Warnings:  toWarningJSON(checkDangerousCommand(command)),
+		Project:   project,
+		LatencyMS: latency.Milliseconds(),
+	})
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// fakeOllama serves each response in turn from /api/generate and points
+// OLLAMA_HOST at it for the duration of the test.
+func fakeOllama(t *testing.T, responses ...string) {
+	t.Helper()
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := responses[len(responses)-1]
+		if calls < len(responses) {
+			resp = responses[calls]
+		}
+		calls++
+		json.NewEncoder(w).Encode(ollamaResponse{Response: resp})
+	}))
+	t.Cleanup(srv.Close)
+	t.Setenv("OLLAMA_HOST", srv.URL)
+}
+
+// captureStdout returns everything fn writes to os.Stdout.
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureOutput(t, &os.Stdout, fn)
+}
+
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureOutput(t, &os.Stderr, fn)
+}
+
+// captureOutput returns what fn writes to *target.
+func captureOutput(t *testing.T, target **os.File, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := *target
+	*target = w
+	defer func() { *target = orig }()
+
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	fn()
+	w.Close()
+	return <-done
+}
+
+func newTestStats() *Stats {
+	return &Stats{Models: make(map[string]int)}
+}
+
+func TestGenerateForScriptPrint(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	fakeOllama(t, "```sh\nls -la\n```")
+
+	var err error
+	out := captureStdout(t, func() {
+		err = generateForScript("test-model", "list files", false, newTestStats())
+	})
+	if err != nil {
+		t.Fatalf("generateForScript() error: %v", err)
+	}
+	if out != "ls -la\n" {
+		t.Errorf("--print output = %q, want only the command", out)
+	}
+}
+
+func TestGenerateForScriptJSON(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	fakeOllama(t, "rm -rf /tmp/build")
+	stats := newTestStats()
+
+	var err error
+	out := captureStdout(t, func() {
+		err = generateForScript("test-model", "delete the build dir", true, stats)
+	})
+	if err != nil {
+		t.Fatalf("generateForScript() error: %v", err)
+	}
+
+	var got commandResult
+	if err := json.Unmarshal([]byte(out), &got); err != nil {
+		t.Fatalf("--json output is not valid JSON: %v\n%s", err, out)
+	}
+	if got.Command != "rm -rf /tmp/build" || got.Model != "test-model" || got.Query != "delete the build dir" {
+		t.Errorf("unexpected result: %+v", got)
+	}
+	if len(got.Warnings) == 0 {
+		t.Error("--json output should include danger warnings")
+	}
+	if got.Project == nil {
+		t.Error("project should be an empty list, not null")
+	}
+	if !strings.Contains(out, `"latency_ms"`) {
+		t.Error("--json output should include latency_ms")
+	}
+	if stats.Counters.CommandsGenerated != 1 {
+		t.Errorf("CommandsGenerated = %d, want 1", stats.Counters.CommandsGenerated)
+	}
+}
+
+func TestToWarningJSONEmpty(t *testing.T) {
+	data, _ := json.Marshal(toWarningJSON(nil))
+	if string(data) != "[]" {
+		t.Errorf("toWarningJSON(nil) = %s, want []", data)
+	}
+}
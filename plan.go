@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const maxPlanSteps = 10
+
+var planStepRe = regexp.MustCompile(`^\s*(\d+)[.)]\s+(.*)$`)
+
+func buildPlanPrompt(userInput string) string {
+	return renderTemplateOrDefault(planTemplateName, newPromptData(userInput))
+}
+
+// parsePlan extracts the commands from a numbered list. If the model ignored
+// the numbering, every non-empty line is taken as a step.
+func parsePlan(s string) []string {
+	var numbered, plain []string
+	for _, line := range strings.Split(stripCodeFences(strings.TrimSpace(s)), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if m := planStepRe.FindStringSubmatch(line); m != nil {
+			numbered = append(numbered, stripCodeFences(strings.TrimSpace(m[2])))
+		} else {
+			plain = append(plain, stripCodeFences(line))
+		}
+	}
+	steps := numbered
+	if len(steps) == 0 {
+		steps = plain
+	}
+	if len(steps) > maxPlanSteps {
+		steps = steps[:maxPlanSteps]
+	}
+	return steps
+}
+
+// generatePlan asks the model for an ordered list of steps for userInput.
+func generatePlan(model, userInput string) ([]string, error) {
+	result, err := generate(model, buildPlanPrompt(userInput))
+	if err != nil {
+		return nil, err
+	}
+	steps := parsePlan(result)
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("the model returned an empty plan")
+	}
+	return steps, nil
+}
+
+// printPlan shows every step with its danger warnings before anything runs.
+func printPlan(steps []string) {
+	fmt.Fprintln(os.Stderr, "Plan:")
+	for i, step := range steps {
+		fmt.Fprintf(os.Stderr, "  %d. %s\n", i+1, step)
+		printWarnings(checkDangerousCommand(step))
+	}
+	fmt.Fprintln(os.Stderr)
+}
+
+type stepAction int
+
+const (
+	stepRun stepAction = iota
+	stepSkip
+	stepEdit
+	stepAbort
+)
+
+func parseStepAction(answer string) (stepAction, bool) {
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "", "y", "yes":
+		return stepRun, true
+	case "s", "skip", "n", "no":
+		return stepSkip, true
+	case "e", "edit":
+		return stepEdit, true
+	case "a", "abort", "q", "quit":
+		return stepAbort, true
+	}
+	return 0, false
+}
+
+// runPlan shows the plan, then runs it one step at a time with
+// confirm/skip/edit/abort on the terminal (or unattended with --yes).
+func runPlan(request string, steps []string, stats *Stats) {
+	printPlan(steps)
+
+	if assumeYes {
+		executePlan(request, steps, nil, stats)
+		return
+	}
+	in := confirmationInput()
+	if in == nil {
+		fmt.Fprintln(os.Stderr, "not running: no terminal to confirm on (use --yes to run without confirmation)")
+		return
+	}
+	if in != os.Stdin {
+		defer in.Close()
+	}
+	executePlan(request, steps, bufio.NewScanner(in), stats)
+}
+
+// executePlan runs steps in order, reading each decision from answers (nil
+// runs every step), and stops at the first failing step. Each step is
+// checked like a single command, with its warnings, blast radius and policy. Each step's output
+// goes into the command history, so later prompts can refer to it, and the
+// audit log, under the plan's request.
+func executePlan(request string, steps []string, answers *bufio.Scanner, stats *Stats) {
+	steps = append([]string(nil), steps...) // edits stay local to this run
+	for i := 0; i < len(steps); i++ {
+		step := steps[i]
+		label := fmt.Sprintf("[%d/%d]", i+1, len(steps))
+		fmt.Fprintf(os.Stderr, "%s %s\n", label, paint(os.Stderr, colorYellow, step))
+		// worked out now, after the steps before it have run
+		outcome := guardCommand(step, nil)
+		if !outcome.allowed {
+			fmt.Fprintln(os.Stderr, paint(os.Stderr, colorRed, fmt.Sprintf("step %d not run — stopping", i+1)))
+			return
+		}
+		if answers != nil && !outcome.confirmed {
+			fmt.Fprintf(os.Stderr, "%s [Enter=run, s=skip, e=edit, a=abort] ", label)
+			if !answers.Scan() {
+				fmt.Fprintln(os.Stderr)
+				return
+			}
+			action, ok := parseStepAction(answers.Text())
+			if !ok {
+				i-- // ask again
+				continue
+			}
+			switch action {
+			case stepSkip:
+				continue
+			case stepAbort:
+				fmt.Fprintln(os.Stderr, "plan aborted")
+				return
+			case stepEdit:
+				fmt.Fprint(os.Stderr, "new command: ")
+				if !answers.Scan() {
+					return
+				}
+				if edited := strings.TrimSpace(answers.Text()); edited != "" {
+					steps[i] = edited
+				}
+				i-- // check and confirm the edited step
+				continue
+			}
+		}
+
+		snapshotBeforeRun(step, outcome.blast)
+		if stats != nil {
+			stats.RecordExecution()
+		}
+		if err := runAudited(request, step, outcome, func() error { return runCommand(step) }); err != nil {
+			fmt.Fprintln(os.Stderr, paint(os.Stderr, colorRed, fmt.Sprintf("step %d failed: %v — stopping", i+1, err)))
+			return
+		}
+	}
+}
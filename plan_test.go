@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePlan(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "numbered",
+			input: "1. git checkout -b release\n2. npm version patch\n3) git commit -am 'bump'",
+			want:  []string{"git checkout -b release", "npm version patch", "git commit -am 'bump'"},
+		},
+		{
+			name:  "fenced with backticks",
+			input: "```\n1. `make build`\n2. `make test`\n```",
+			want:  []string{"make build", "make test"},
+		},
+		{
+			name:  "numbered wins over chatter",
+			input: "Here is the plan:\n1. ls\n2. pwd",
+			want:  []string{"ls", "pwd"},
+		},
+		{
+			name:  "unnumbered",
+			input: "mkdir out\n\n# comment\ncp a out/",
+			want:  []string{"mkdir out", "cp a out/"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parsePlan(tt.input); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePlan() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var long []string
+	for i := 0; i < maxPlanSteps+5; i++ {
+		long = append(long, "1. echo hi")
+	}
+	if got := parsePlan(strings.Join(long, "\n")); len(got) != maxPlanSteps {
+		t.Errorf("parsePlan() returned %d steps, want %d", len(got), maxPlanSteps)
+	}
+}
+
+func TestBuildPlanPrompt(t *testing.T) {
+	prompt := buildPlanPrompt("create a branch and commit")
+	for _, want := range []string{"numbered list", "User request: create a branch and commit"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("buildPlanPrompt() missing %q", want)
+		}
+	}
+}
+
+func TestParseStepAction(t *testing.T) {
+	tests := map[string]stepAction{"": stepRun, "y": stepRun, "s": stepSkip, "E": stepEdit, "abort": stepAbort}
+	for in, want := range tests {
+		if got, ok := parseStepAction(in); !ok || got != want {
+			t.Errorf("parseStepAction(%q) = %v, %v; want %v", in, got, ok, want)
+		}
+	}
+	if _, ok := parseStepAction("maybe"); ok {
+		t.Error("parseStepAction(maybe) should not be accepted")
+	}
+}
+
+// planDir returns a temp dir that the test runs in and restores afterwards.
+func planDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	orig, _ := os.Getwd()
+	os.Chdir(dir)
+	t.Cleanup(func() { os.Chdir(orig) })
+	t.Setenv("HOME", t.TempDir())
+	withPipe(t, &os.Stderr)
+	resetHistory()
+	return dir
+}
+
+func TestExecutePlanConfirmSkipEdit(t *testing.T) {
+	dir := planDir(t)
+
+	steps := []string{"touch a", "touch b", "touch c", "touch d"}
+	// run a, skip b, edit c into e, then abort before d
+	answers := bufio.NewScanner(strings.NewReader("\ns\ne\ntouch e\n\na\n"))
+	captureStdout(t, func() { executePlan("test", steps, answers, newTestStats()) })
+
+	for name, want := range map[string]bool{"a": true, "b": false, "c": false, "e": true, "d": false} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if (err == nil) != want {
+			t.Errorf("file %s exists = %v, want %v", name, err == nil, want)
+		}
+	}
+	if len(commandHistory) != 2 || commandHistory[1].command != "touch e" {
+		t.Errorf("commandHistory = %+v, want the two executed steps", commandHistory)
+	}
+	if steps[2] != "touch c" {
+		t.Errorf("executePlan() edited the caller's steps: %q", steps)
+	}
+}
+
+func TestExecutePlanShowsBlastRadius(t *testing.T) {
+	dir := planDir(t)
+	os.MkdirAll(filepath.Join(dir, "build"), 0755)
+	os.WriteFile(filepath.Join(dir, "build", "a.o"), []byte("aaaa"), 0644)
+
+	var stderr string
+	captureStdout(t, func() {
+		stderr = captureStderr(t, func() { executePlan("test", []string{"rm -rf build"}, nil, newTestStats()) })
+	})
+	for _, want := range []string{"[1/1] rm -rf build", "rm -rf build:", "build/a.o"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("executePlan() stderr missing %q:\n%s", want, stderr)
+		}
+	}
+}
+
+func TestExecutePlanStopsOnFailure(t *testing.T) {
+	dir := planDir(t)
+
+	stats := newTestStats()
+	steps := []string{"touch first", "false", "touch never"}
+	captureStdout(t, func() { executePlan("test", steps, nil, stats) })
+
+	if _, err := os.Stat(filepath.Join(dir, "first")); err != nil {
+		t.Error("first step should have run")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "never")); err == nil {
+		t.Error("steps after a failure should not run")
+	}
+	if stats.Counters.CommandsExecuted != 2 {
+		t.Errorf("CommandsExecuted = %d, want 2", stats.Counters.CommandsExecuted)
+	}
+}
+
+func TestExecutePlanCdPersists(t *testing.T) {
+	dir := planDir(t)
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+
+	captureStdout(t, func() { executePlan("test", []string{"cd sub", "touch here"}, nil, newTestStats()) })
+	if _, err := os.Stat(filepath.Join(dir, "sub", "here")); err != nil {
+		t.Error("a cd step should change the directory for later steps")
+	}
+}
+
+func TestExecutePlanSnapshots(t *testing.T) {
+	dir := planDir(t)
+	os.MkdirAll(filepath.Join(dir, "build"), 0755)
+	os.WriteFile(filepath.Join(dir, "build", "a.o"), []byte("aaaa"), 0644)
+	defer func(on bool) { snapshotFiles = on }(snapshotFiles)
+	snapshotFiles = true
+
+	captureStdout(t, func() { executePlan("test", []string{"rm -rf build", "touch done"}, nil, newTestStats()) })
+	snapshots := listSnapshots(trashDir())
+	if len(snapshots) != 1 {
+		t.Fatalf("got %d snapshots, want one for the rm step", len(snapshots))
+	}
+	m, err := loadManifest(snapshots[0])
+	if err != nil || m.Command != "rm -rf build" {
+		t.Errorf("snapshot manifest = %+v, %v", m, err)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Policies decide what happens to a command whose warnings have a given
+// severity: warn only prints them, confirm makes the user type "yes" or the
+// command's first word, and block refuses to run it unless --force is given.
+// They are set per severity in the rules files (see rulesFileName).
+const (
+	policyWarn    = "warn"
+	policyConfirm = "confirm"
+	policyBlock   = "block"
+)
+
+var policyRank = map[string]int{policyWarn: 0, policyConfirm: 1, policyBlock: 2}
+
+// forcePolicy (--force) overrides policies: a blocked command can run after
+// typed confirmation, and with --yes no typed confirmation is asked for.
+var forcePolicy bool
+
+// validatePolicies checks a "policies" mapping from a rules file.
+func validatePolicies(policies map[string]string) error {
+	var severities []string
+	for severity := range policies {
+		severities = append(severities, severity)
+	}
+	sort.Strings(severities)
+	for _, severity := range severities {
+		if severity != "high" && severity != "medium" {
+			return fmt.Errorf("unknown severity %q in policies (expected high or medium)", severity)
+		}
+		if _, ok := policyRank[policies[severity]]; !ok {
+			return fmt.Errorf("unknown policy %q for %s (expected warn, confirm or block)", policies[severity], severity)
+		}
+	}
+	return nil
+}
+
+// activePolicies returns the policy for each severity: warn unless the
+// global or project rules file says otherwise. A project file can only make
+// a policy stricter, so a cloned repository can't turn off a block.
+func activePolicies(dir string) map[string]string {
+	policies := map[string]string{"high": policyWarn, "medium": policyWarn}
+	for _, path := range ruleFilePaths(dir) {
+		rf, err := loadRulesFile(path)
+		if err != nil {
+			reportRuleErrors([]error{err})
+			continue
+		}
+		for severity, policy := range rf.policies {
+			if policyRank[policy] > policyRank[policies[severity]] {
+				policies[severity] = policy
+			}
+		}
+	}
+	return policies
+}
+
+// strictestPolicy returns the strictest policy among the severities of
+// warnings, and the severity it applies to.
+func strictestPolicy(warnings []compiledPattern, policies map[string]string) (string, string) {
+	policy, severity := policyWarn, ""
+	for _, w := range warnings {
+		if p := policies[w.severity]; policyRank[p] > policyRank[policy] {
+			policy, severity = p, w.severity
+		}
+	}
+	return policy, severity
+}
+
+// policyOutcome is what enforcing the policies on a command decided.
+type policyOutcome struct {
+	policy     string // the strictest policy that applied
+	allowed    bool   // the command may run
+	confirmed  bool   // the user typed the confirmation
+	overridden bool   // --force let a blocked or unconfirmed command run
+	warnings   []compiledPattern
+	blast      []*blastEntry // what the command would affect, as shown
+}
+
+// enforcePolicy applies the strictest policy among the severities of
+// warnings to cmd, asking for typed confirmation if the policy requires it.
+func enforcePolicy(cmd string, warnings []compiledPattern) policyOutcome {
+	cwd, _ := os.Getwd()
+	policy, severity := strictestPolicy(warnings, activePolicies(cwd))
+	out := policyOutcome{policy: policy, allowed: true, warnings: warnings}
+
+	switch out.policy {
+	case policyWarn:
+		return out
+	case policyBlock:
+		if !forcePolicy {
+			fmt.Fprintln(os.Stderr, paint(os.Stderr, colorRed, fmt.Sprintf("blocked: %s-severity commands are blocked by policy (use --force to override)", severity)))
+			out.allowed = false
+			return out
+		}
+		out.overridden = true
+	}
+
+	if assumeYes {
+		if !forcePolicy {
+			fmt.Fprintln(os.Stderr, paint(os.Stderr, colorYellow, "→ "+cmd))
+			fmt.Fprintf(os.Stderr, "not running: %s-severity commands must be confirmed by typing (use --force with --yes to override)\n", severity)
+			out.allowed = false
+			return out
+		}
+		out.overridden = true
+		return out
+	}
+	out.allowed = confirmTyped(cmd)
+	out.confirmed = out.allowed
+	return out
+}
+
+// confirmTyped shows cmd and reports whether the user typed "yes" or its
+// first word. Like confirm, it refuses without a terminal.
+func confirmTyped(cmd string) bool {
+	word := "yes"
+	if fields := strings.Fields(cmd); len(fields) > 0 {
+		word = fields[0]
+	}
+	shown := paint(os.Stderr, colorYellow, "→ "+cmd)
+	in := confirmationInput()
+	if in == nil {
+		fmt.Fprintln(os.Stderr, shown)
+		fmt.Fprintln(os.Stderr, "not running: no terminal to confirm on")
+		return false
+	}
+	if in != os.Stdin {
+		defer in.Close()
+	}
+	fmt.Fprintf(os.Stderr, "%s [type yes or %s to run] ", shown, word)
+	scanner := bufio.NewScanner(in)
+	scanner.Scan()
+	answer := strings.TrimSpace(scanner.Text())
+	if answer != "yes" && answer != word {
+		fmt.Fprintln(os.Stderr, "not running")
+		return false
+	}
+	return true
+}
+
+// shownBlast returns what cmd would affect that is worth showing: all of it
+// for a flagged command, otherwise only the files it would overwrite.
+func shownBlast(cmd string, warnings []compiledPattern) []*blastEntry {
+	var shown []*blastEntry
+	for _, e := range blastRadius(cmd) {
+		if len(warnings) > 0 || e.overwrites {
+			shown = append(shown, e)
+		}
+	}
+	return shown
+}
+
+// guardCommand prints cmd's warnings and what it would destroy, and enforces
+// the policies on them. The blast radius is shown for flagged commands, and
+// for any command that overwrites existing files. A model assessment, if
+// there is one, is shown after the warnings; it never changes the policy.
+func guardCommand(cmd string, assessment *riskAssessment) policyOutcome {
+	warnings := checkDangerousCommand(cmd)
+	printWarnings(warnings)
+	if assessment != nil {
+		printAssessment(assessment, warnings)
+	}
+	shown := shownBlast(cmd, warnings)
+	printBlastRadius(shown)
+	if snapshotFiles {
+		printSnapshotNotice(shown)
+	}
+	out := enforcePolicy(cmd, warnings)
+	out.blast = shown
+	return out
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEnforcePolicy(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeRules(t, home, "policies:\n  high: block\n  medium: confirm\n")
+	withPipe(t, &os.Stderr)
+	defer func(yes, force bool) { assumeYes, forcePolicy = yes, force }(assumeYes, forcePolicy)
+
+	high := []compiledPattern{{severity: "medium"}, {severity: "high"}}
+	medium := []compiledPattern{{severity: "medium"}}
+	tests := []struct {
+		warnings   []compiledPattern
+		yes, force bool
+		policy     string
+		allowed    bool
+		overridden bool
+	}{
+		{nil, true, false, policyWarn, true, false},
+		{high, true, false, policyBlock, false, false},
+		{high, true, true, policyBlock, true, true},
+		{medium, true, false, policyConfirm, false, false},
+		{medium, true, true, policyConfirm, true, true},
+	}
+	for _, tt := range tests {
+		assumeYes, forcePolicy = tt.yes, tt.force
+		out := enforcePolicy("rm -rf build", tt.warnings)
+		if out.policy != tt.policy || out.allowed != tt.allowed || out.overridden != tt.overridden {
+			t.Errorf("enforcePolicy(%v, yes=%v, force=%v) = %+v", tt.warnings, tt.yes, tt.force, out)
+		}
+	}
+}
+
+func TestActivePoliciesProjectTightens(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeRules(t, home, "policies:\n  high: block\n")
+	project := t.TempDir()
+	writeRules(t, project, "policies:\n  high: warn\n  medium: confirm\n")
+
+	policies := activePolicies(project)
+	if policies["high"] != policyBlock || policies["medium"] != policyConfirm {
+		t.Errorf("activePolicies = %v, want high block and medium confirm", policies)
+	}
+	if policies := activePolicies(t.TempDir()); policies["high"] != policyBlock || policies["medium"] != policyWarn {
+		t.Errorf("activePolicies outside the project = %v, want high block and medium warn", policies)
+	}
+}
@@ -9,8 +9,8 @@ import (
 
 // ProjectType represents a detected project type with its common tools
 type ProjectType struct {
-	Name  string
-	Tools []string
+	Name  string   `json:"name"`
+	Tools []string `json:"tools"`
 }
 
 // signatureFile maps a file pattern to a project type
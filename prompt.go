@@ -63,36 +63,40 @@ func formatHistory() string {
 	return strings.Join(lines, "\n")
 }
 
-func buildPrompt(userInput string) string {
+// newPromptData collects the environment details shared by all prompt templates.
+func newPromptData(userInput string) PromptData {
 	cwd, _ := os.Getwd()
 	shell := os.Getenv("SHELL")
 	if shell == "" {
 		shell = "/bin/sh"
 	}
-	osName := runtime.GOOS
-	history := formatHistory()
-	projectInfo := formatProjectInfo(cwd)
-
-	projectLine := ""
-	if projectInfo != "" {
-		projectLine = projectInfo + "\n"
+	askFile, err := loadAskFile(cwd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
 	}
+	aliases := ""
+	if useUserShell {
+		aliases = loadShellAliases(targetDialect).format()
+	}
+	return PromptData{
+		Cwd:          cwd,
+		OS:           runtime.GOOS,
+		Shell:        shell,
+		Dialect:      string(targetDialect),
+		DialectRules: dialectRules[targetDialect],
+		Tools:        loadInstalledTools().format(),
+		Aliases:      aliases,
+		History:      formatHistory(),
+		ProjectInfo:  formatProjectInfo(cwd),
+		Conventions:  askFile.formatConventions(),
+		Request:      userInput,
+	}
+}
 
-	return fmt.Sprintf(`You are a shell command translator. Convert the user's request into a shell command.
-Current directory: %s
-Operating system: %s
-Shell: %s
-%s
-Recent command history:
-%s
-
-Rules:
-- Output ONLY the command, nothing else
-- No explanations, no markdown, no backticks
-- If unclear, make a reasonable assumption
-- Prefer simple, common commands
-- Use the command history for context (e.g., "do that again", "delete the file I just created")
-- When applicable, prefer project-specific tools (e.g., "go test" for Go, "npm test" for Node.js)
-
-User request: %s`, cwd, osName, shell, projectLine, history, userInput)
+func buildPrompt(userInput string) string {
+	data := newPromptData(userInput)
+	if pipedInput != nil {
+		data.Input = sampleInput(pipedInput)
+	}
+	return renderTemplateOrDefault(commandTemplateName, data)
 }
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// assessRisk (--assess, ASK_ASSESS) asks the model for a second opinion on
+// the risk of each generated command before it runs. ASK_ASSESS_MODEL picks a
+// different model for it.
+var assessRisk bool
+
+// riskAssessment is the model's opinion of a command. It is advisory: when it
+// disagrees with the danger rules, the rules decide.
+type riskAssessment struct {
+	Risk       string `json:"risk"`       // low, medium or high
+	Reversible string `json:"reversible"` // yes, partly or no
+	Reason     string `json:"reason"`
+	Rules      string `json:"rules,omitempty"` // the strictest severity the rules gave the command
+}
+
+var (
+	riskLevels       = []string{"low", "medium", "high"}
+	reversibleLevels = []string{"yes", "partly", "no"}
+)
+
+// assessModel returns the model to assess commands with.
+func assessModel(model string) string {
+	if m := os.Getenv("ASK_ASSESS_MODEL"); m != "" {
+		return m
+	}
+	return model
+}
+
+func buildAssessPrompt(request, cmd string) string {
+	data := newPromptData(request)
+	data.Command = cmd
+	data.KubeContext = currentKubeContext()
+	data.GitBranch = currentGitBranch(data.Cwd)
+	return renderTemplateOrDefault(assessTemplateName, data)
+}
+
+// matchLevel returns the level that value starts with, or "".
+func matchLevel(value string, levels []string) string {
+	value = strings.ToLower(strings.Trim(value, " *.`"))
+	for _, level := range levels {
+		if strings.HasPrefix(value, level) {
+			return level
+		}
+	}
+	return ""
+}
+
+// parseAssessment reads the "Risk:", "Reversible:" and "Reason:" lines of
+// the model's answer.
+func parseAssessment(s string) (*riskAssessment, error) {
+	var a riskAssessment
+	for _, line := range strings.Split(s, "\n") {
+		key, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.Trim(key, "*- ")) {
+		case "risk":
+			a.Risk = matchLevel(value, riskLevels)
+		case "reversible":
+			a.Reversible = matchLevel(value, reversibleLevels)
+		case "reason":
+			a.Reason = stripMarkdown(strings.Trim(value, " *"))
+		}
+	}
+	if a.Risk == "" || a.Reversible == "" {
+		return nil, fmt.Errorf("unexpected risk assessment from the model: %q", strings.TrimSpace(s))
+	}
+	return &a, nil
+}
+
+// assessCommand asks the model to rate cmd, generated for request.
+func assessCommand(model, request, cmd string) (*riskAssessment, error) {
+	spinner := NewSpinner("Assessing risk...")
+	spinner.Start()
+	result, err := generate(assessModel(model), buildAssessPrompt(request, cmd))
+	spinner.Stop()
+	if err != nil {
+		return nil, err
+	}
+	return parseAssessment(result)
+}
+
+// strictestSeverity returns the highest severity among warnings, or "".
+func strictestSeverity(warnings []compiledPattern) string {
+	severity := ""
+	for _, w := range warnings {
+		if severityRank[w.severity] > severityRank[severity] {
+			severity = w.severity
+		}
+	}
+	return severity
+}
+
+// formatAssessment describes a next to the rule warnings. When the model and
+// the rules disagree, it says that the rules apply.
+func formatAssessment(a *riskAssessment, warnings []compiledPattern) string {
+	reversible := map[string]string{"yes": "reversible", "partly": "partly reversible", "no": "irreversible"}[a.Reversible]
+	text := fmt.Sprintf("  ◆ Model assessment: %s risk, %s", a.Risk, reversible)
+	if a.Reason != "" {
+		text += " — " + a.Reason
+	}
+	switch rules := strictestSeverity(warnings); {
+	case rules == "" && a.Risk != "low":
+		text += " (advisory: no rule flags this command)"
+	case rules != "" && severityRank[rules] != severityRank[a.Risk]:
+		text += fmt.Sprintf(" (the rules rate it %s, and they apply)", rules)
+	}
+	return text
+}
+
+func printAssessment(a *riskAssessment, warnings []compiledPattern) {
+	color := colorCyan
+	switch a.Risk {
+	case "high":
+		color = colorRed
+	case "medium":
+		color = colorYellow
+	}
+	fmt.Fprintln(os.Stderr, paint(os.Stderr, color, formatAssessment(a, warnings)))
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseAssessment(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		risk       string
+		reversible string
+		reason     string
+		wantErr    bool
+	}{
+		{"plain", "Risk: high\nReversible: no\nReason: Deletes the production database.", "high", "no", "Deletes the production database.", false},
+		{"markdown", "**Risk:** Medium\n**Reversible:** partly.\n**Reason:** Restarts **every** pod.", "medium", "partly", "Restarts every pod.", false},
+		{"extra text", "Here is my rating.\nRisk: low - read only\nReversible: yes\nReason: Only lists files.", "low", "yes", "Only lists files.", false},
+		{"no reason", "Risk: low\nReversible: yes", "low", "yes", "", false},
+		{"unknown level", "Risk: extreme\nReversible: no\nReason: x", "", "", "", true},
+		{"prose", "This command is pretty safe.", "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := parseAssessment(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseAssessment() = %+v, want an error", a)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if a.Risk != tt.risk || a.Reversible != tt.reversible || a.Reason != tt.reason {
+				t.Errorf("parseAssessment() = %+v", a)
+			}
+		})
+	}
+}
+
+func TestFormatAssessment(t *testing.T) {
+	high := []compiledPattern{{message: "Recursive force delete", severity: "high"}}
+	medium := []compiledPattern{{message: "Force push", severity: "medium"}}
+	tests := []struct {
+		name     string
+		a        riskAssessment
+		warnings []compiledPattern
+		want     string
+	}{
+		{"agree", riskAssessment{Risk: "high", Reversible: "no", Reason: "Deletes data."}, high,
+			"  ◆ Model assessment: high risk, irreversible — Deletes data."},
+		{"model lower", riskAssessment{Risk: "low", Reversible: "yes", Reason: "Cleans a build dir."}, high,
+			"  ◆ Model assessment: low risk, reversible — Cleans a build dir. (the rules rate it high, and they apply)"},
+		{"model higher", riskAssessment{Risk: "high", Reversible: "partly"}, medium,
+			"  ◆ Model assessment: high risk, partly reversible (the rules rate it medium, and they apply)"},
+		{"only the model", riskAssessment{Risk: "medium", Reversible: "partly", Reason: "Scales prod to zero."}, nil,
+			"  ◆ Model assessment: medium risk, partly reversible — Scales prod to zero. (advisory: no rule flags this command)"},
+		{"both safe", riskAssessment{Risk: "low", Reversible: "yes"}, nil,
+			"  ◆ Model assessment: low risk, reversible"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := formatAssessment(&tt.a, tt.warnings); got != tt.want {
+				t.Errorf("formatAssessment() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildAssessPrompt(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(dir+"/.kube", 0755)
+	os.WriteFile(dir+"/.kube/config", []byte("apiVersion: v1\ncurrent-context: prod-eu\n"), 0644)
+	t.Setenv("HOME", dir)
+	t.Setenv("KUBECONFIG", "")
+
+	prompt := buildAssessPrompt("stop the web app", "kubectl scale deploy/web --replicas=0")
+	for _, want := range []string{"kubectl context: prod-eu", "User request: stop the web app", "Command: kubectl scale deploy/web --replicas=0", "Risk: low, medium or high"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("buildAssessPrompt() missing %q:\n%s", want, prompt)
+		}
+	}
+}
+
+func TestConfirmAndRunAssessment(t *testing.T) {
+	planDir(t)
+	t.Setenv("NO_COLOR", "1")
+	t.Setenv("ASK_ASSESS_MODEL", "")
+	fakeOllama(t, "Risk: high\nReversible: no\nReason: Lists secrets on screen.")
+	assessRisk = true
+	t.Cleanup(func() { assessRisk = false })
+
+	stats := newTestStats()
+	stats.RecordOneshotCommand("test-model", "list files", "ls")
+	out := captureStderr(t, func() { confirmAndRun("test-model", "list files", "ls", stats) })
+
+	if !strings.Contains(out, "Model assessment: high risk, irreversible — Lists secrets on screen. (advisory: no rule flags this command)") {
+		t.Errorf("assessment not shown:\n%s", out)
+	}
+	a := stats.History[0].Assessment
+	if a == nil || a.Risk != "high" || a.Rules != "" {
+		t.Errorf("history assessment = %+v", a)
+	}
+}
@@ -0,0 +1,499 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// rulesFileName is the danger rules file, read from ~/.ask and from the
+// nearest .ask directory of the project, e.g.:
+//
+//	rules:
+//	  - pattern: '^kubectl\s+delete\b'
+//	    message: Deleting resources in the production cluster
+//	    severity: high
+//	    kube_context: prod
+//	  - pattern: '^terraform\s+apply\b'
+//	    unless: '-target\b'
+//	    message: terraform apply without -target changes everything
+//
+// Patterns are matched against each command a command line runs (see
+// invocations.addList). The optional scope fields all have to hold for a rule
+// to fire. An optional "policies" mapping sets what happens to commands of
+// each severity (see enforcePolicy):
+//
+//	policies:
+//	  high: confirm
+//	  medium: warn
+const rulesFileName = "rules.yaml"
+
+// rulesFile is a loaded rules file.
+type rulesFile struct {
+	rules    []compiledPattern
+	policies map[string]string // severity to policy
+}
+
+// ruleSpec is one rule as written in a rules file.
+type ruleSpec struct {
+	Pattern     string            `yaml:"pattern"`
+	Message     string            `yaml:"message"`
+	Severity    string            `yaml:"severity"`
+	Unless      string            `yaml:"unless"`
+	Dirs        []string          `yaml:"dirs"`
+	Env         map[string]string `yaml:"env"`
+	KubeContext string            `yaml:"kube_context"`
+	GitBranch   string            `yaml:"git_branch"`
+}
+
+var ruleFields = []string{"pattern", "message", "severity", "unless", "dirs", "env", "kube_context", "git_branch"}
+
+// ruleScope holds the conditions that limit where a rule applies. A nil
+// scope always applies.
+type ruleScope struct {
+	unless      *regexp.Regexp            // the command must not match
+	dirs        []string                  // cwd must be in or under one of these globs
+	env         map[string]*regexp.Regexp // variables that must match
+	kubeContext *regexp.Regexp            // kubectl context (--context or current-context)
+	gitBranch   *regexp.Regexp            // current git branch
+}
+
+var kubeContextFlagRe = regexp.MustCompile(`--context[= ](\S+)`)
+
+// applies reports whether a rule with this scope applies to the command text.
+func (s *ruleScope) applies(text string) bool {
+	if s == nil {
+		return true
+	}
+	if s.unless != nil && s.unless.MatchString(text) {
+		return false
+	}
+	for name, re := range s.env {
+		if !re.MatchString(os.Getenv(name)) {
+			return false
+		}
+	}
+	cwd, _ := os.Getwd()
+	if len(s.dirs) > 0 && !inAnyDir(cwd, s.dirs) {
+		return false
+	}
+	if s.kubeContext != nil {
+		context := currentKubeContext()
+		if m := kubeContextFlagRe.FindStringSubmatch(text); m != nil {
+			context = m[1]
+		}
+		if !s.kubeContext.MatchString(context) {
+			return false
+		}
+	}
+	if s.gitBranch != nil && !s.gitBranch.MatchString(currentGitBranch(cwd)) {
+		return false
+	}
+	return true
+}
+
+// describe renders the scope for "ask rules list".
+func (s *ruleScope) describe() string {
+	if s == nil {
+		return ""
+	}
+	var parts []string
+	if s.unless != nil {
+		parts = append(parts, "unless "+s.unless.String())
+	}
+	if len(s.dirs) > 0 {
+		parts = append(parts, "dirs "+strings.Join(s.dirs, ", "))
+	}
+	var names []string
+	for name := range s.env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("env %s=~%s", name, s.env[name]))
+	}
+	if s.kubeContext != nil {
+		parts = append(parts, "kube_context "+s.kubeContext.String())
+	}
+	if s.gitBranch != nil {
+		parts = append(parts, "git_branch "+s.gitBranch.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// inAnyDir reports whether dir, or one of its parents, matches a glob.
+func inAnyDir(dir string, globs []string) bool {
+	for _, glob := range globs {
+		glob = filepath.Clean(expandHome(glob))
+		for d := dir; ; d = filepath.Dir(d) {
+			if ok, _ := filepath.Match(glob, d); ok {
+				return true
+			}
+			if filepath.Dir(d) == d {
+				break
+			}
+		}
+	}
+	return false
+}
+
+// currentKubeContext reads current-context from the kubeconfig without
+// running kubectl.
+func currentKubeContext() string {
+	home, _ := os.UserHomeDir()
+	path := filepath.Join(home, ".kube", "config")
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		path = filepath.SplitList(env)[0]
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if value, ok := strings.CutPrefix(scanner.Text(), "current-context:"); ok {
+			return strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	return ""
+}
+
+// currentGitBranch returns the branch checked out in the repository
+// containing dir, or "" outside a repository or on a detached HEAD.
+func currentGitBranch(dir string) string {
+	gitPath := findUp(dir, ".git")
+	if gitPath == "" {
+		return ""
+	}
+	gitDir := gitPath
+	if info, err := os.Stat(gitPath); err == nil && !info.IsDir() {
+		// a worktree or submodule: "gitdir: <path>"
+		data, err := os.ReadFile(gitPath)
+		if err != nil {
+			return ""
+		}
+		gitDir = strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+		if !filepath.IsAbs(gitDir) {
+			gitDir = filepath.Join(filepath.Dir(gitPath), gitDir)
+		}
+	}
+	head, err := os.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	branch, ok := strings.CutPrefix(strings.TrimSpace(string(head)), "ref: refs/heads/")
+	if !ok {
+		return ""
+	}
+	return branch
+}
+
+// ruleFilePaths returns the global rules file and the nearest project rules
+// file found walking up from dir, if they exist.
+func ruleFilePaths(dir string) []string {
+	home, _ := os.UserHomeDir()
+	global := filepath.Join(home, ".ask", rulesFileName)
+	var paths []string
+	if _, err := os.Stat(global); err == nil {
+		paths = append(paths, global)
+	}
+	if project := findUp(dir, filepath.Join(".ask", rulesFileName)); project != "" && project != global {
+		paths = append(paths, project)
+	}
+	return paths
+}
+
+// fileCache keeps what was loaded from a file for the rest of the run, so
+// checking every step of a plan doesn't read and parse it again. A file that
+// changes is loaded again.
+type fileCache[T any] map[string]cachedFile[T]
+
+type cachedFile[T any] struct {
+	modTime time.Time
+	size    int64
+	value   T
+	err     error
+}
+
+// load returns what load made of path, calling it only if path is new or
+// has changed since.
+func (c fileCache[T]) load(path string, load func(string) (T, error)) (T, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return load(path)
+	}
+	if cached, ok := c[path]; ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.value, cached.err
+	}
+	value, err := load(path)
+	c[path] = cachedFile[T]{modTime: info.ModTime(), size: info.Size(), value: value, err: err}
+	return value, err
+}
+
+var rulesFiles = make(fileCache[*rulesFile])
+
+// loadRulesFile reads and validates a rules file. Any invalid rule or policy
+// makes the whole file fail, with an error naming the file, line and problem.
+func loadRulesFile(path string) (*rulesFile, error) {
+	return rulesFiles.load(path, readRulesFile)
+}
+
+func readRulesFile(path string) (*rulesFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	rf := &rulesFile{}
+	if len(doc.Content) == 0 {
+		return rf, nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%s:%d: expected a mapping with a \"rules\" list", path, root.Line)
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+		switch key.Value {
+		case "rules":
+			if rf.rules, err = compileRules(path, key.Value, value); err != nil {
+				return nil, err
+			}
+		case "policies":
+			if err := value.Decode(&rf.policies); err != nil {
+				return nil, fmt.Errorf("%s:%d: \"policies\" must map severities to policies", path, value.Line)
+			}
+			if err := validatePolicies(rf.policies); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", path, value.Line, err)
+			}
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown key %q (expected \"rules\" or \"policies\")", path, key.Line, key.Value)
+		}
+	}
+	return rf, nil
+}
+
+// compileRules compiles the list of rules under key in the file at path: the
+// "rules" of a rules file, or the "danger_rules" of a .ask.yaml.
+func compileRules(path, key string, list *yaml.Node) ([]compiledPattern, error) {
+	if list.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("%s:%d: %q must be a list", path, list.Line, key)
+	}
+	var rules []compiledPattern
+	for i, node := range list.Content {
+		cp, err := compileRule(node)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: rule %d: %w", path, node.Line, i+1, err)
+		}
+		cp.source = path
+		cp.line = node.Line
+		rules = append(rules, cp)
+	}
+	return rules, nil
+}
+
+func compileRule(node *yaml.Node) (compiledPattern, error) {
+	if node.Kind != yaml.MappingNode {
+		return compiledPattern{}, fmt.Errorf("expected a mapping with pattern, message and severity")
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i].Value
+		known := false
+		for _, f := range ruleFields {
+			known = known || f == key
+		}
+		if !known {
+			return compiledPattern{}, fmt.Errorf("unknown field %q (known fields: %s)", key, strings.Join(ruleFields, ", "))
+		}
+	}
+	var spec ruleSpec
+	if err := node.Decode(&spec); err != nil {
+		return compiledPattern{}, err
+	}
+
+	compile := func(field, expr string) (*regexp.Regexp, error) {
+		if expr == "" {
+			return nil, nil
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q: %w", field, expr, err)
+		}
+		return re, nil
+	}
+
+	if spec.Pattern == "" {
+		return compiledPattern{}, fmt.Errorf("pattern is required")
+	}
+	re, err := compile("pattern", spec.Pattern)
+	if err != nil {
+		return compiledPattern{}, err
+	}
+	switch spec.Severity {
+	case "":
+		spec.Severity = "medium"
+	case "high", "medium":
+	default:
+		return compiledPattern{}, fmt.Errorf("severity must be \"high\" or \"medium\", got %q", spec.Severity)
+	}
+	if spec.Message == "" {
+		spec.Message = "Matches danger rule " + spec.Pattern
+	}
+
+	scope := &ruleScope{dirs: spec.Dirs}
+	if scope.unless, err = compile("unless", spec.Unless); err != nil {
+		return compiledPattern{}, err
+	}
+	if scope.kubeContext, err = compile("kube_context", spec.KubeContext); err != nil {
+		return compiledPattern{}, err
+	}
+	if scope.gitBranch, err = compile("git_branch", spec.GitBranch); err != nil {
+		return compiledPattern{}, err
+	}
+	for _, dir := range spec.Dirs {
+		if _, err := filepath.Match(dir, ""); dir == "" || err != nil {
+			return compiledPattern{}, fmt.Errorf("invalid dirs entry %q", dir)
+		}
+	}
+	for name, expr := range spec.Env {
+		re, err := compile("env "+name, expr)
+		if err != nil {
+			return compiledPattern{}, err
+		}
+		if scope.env == nil {
+			scope.env = make(map[string]*regexp.Regexp)
+		}
+		scope.env[name] = re
+	}
+	if scope.describe() == "" {
+		scope = nil
+	}
+
+	return compiledPattern{re: re, message: spec.Message, severity: spec.Severity, scope: scope}, nil
+}
+
+// loadUserRules loads the global and project rules files. Rules from files
+// that fail to load are left out and the errors returned.
+func loadUserRules(dir string) ([]compiledPattern, []error) {
+	var patterns []compiledPattern
+	var errs []error
+	for _, path := range ruleFilePaths(dir) {
+		rf, err := loadRulesFile(path)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		patterns = append(patterns, rf.rules...)
+	}
+	return patterns, errs
+}
+
+// reportedRuleErrors keeps each rules file error from being printed more
+// than once per run.
+var reportedRuleErrors = make(map[string]bool)
+
+func reportRuleErrors(errs []error) {
+	for _, err := range errs {
+		if !reportedRuleErrors[err.Error()] {
+			reportedRuleErrors[err.Error()] = true
+			fmt.Fprintf(os.Stderr, "warning: %v (rules in this file are ignored)\n", err)
+		}
+	}
+}
+
+// runRulesCommand implements "ask rules list" and "ask rules test CMD".
+func runRulesCommand(args []string) error {
+	usage := fmt.Errorf("usage: ask rules list | ask rules test \"command\"")
+	if len(args) == 0 {
+		return usage
+	}
+	cwd, _ := os.Getwd()
+	patterns, errs := activeDangerRules(cwd)
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+	}
+
+	policies := activePolicies(cwd)
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		printRules(patterns)
+		fmt.Printf("\npolicies: high=%s medium=%s\n", policies["high"], policies["medium"])
+	case args[0] == "test" && len(args) > 1:
+		testRules(strings.Join(args[1:], " "), patterns, policies)
+	default:
+		return usage
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d rules file(s) failed to load", len(errs))
+	}
+	return nil
+}
+
+func (cp compiledPattern) location() string {
+	if cp.line > 0 {
+		return fmt.Sprintf("%s:%d", cp.source, cp.line)
+	}
+	return cp.source
+}
+
+func printRules(patterns []compiledPattern) {
+	source := ""
+	for _, cp := range append(append([]compiledPattern(nil), structuralRules...), patterns...) {
+		if cp.source != source {
+			source = cp.source
+			fmt.Println(paint(os.Stdout, colorCyan, source))
+		}
+		if cp.line > 0 {
+			fmt.Printf("  %-6s  %s (line %d)\n", cp.severity, cp.message, cp.line)
+		} else {
+			fmt.Printf("  %-6s  %s\n", cp.severity, cp.message)
+		}
+		pattern := "(" + cp.structural + ")"
+		if cp.re != nil {
+			pattern = cp.re.String()
+		}
+		fmt.Printf("          %s\n", pattern)
+		if scope := cp.scope.describe(); scope != "" {
+			fmt.Printf("          when: %s\n", scope)
+		}
+	}
+}
+
+func testRules(cmd string, patterns []compiledPattern, policies map[string]string) {
+	inv, matched := matchDangerRules(cmd, patterns)
+	if inv == nil {
+		fmt.Println("Could not parse the command; rules were matched against the raw text.")
+	} else {
+		fmt.Println("Commands checked:")
+		for _, text := range inv.commands {
+			fmt.Println("  " + text)
+		}
+	}
+	fmt.Println()
+	if len(matched) == 0 {
+		fmt.Println("No rules fire.")
+		return
+	}
+	fmt.Println("Rules that fire:")
+	for _, cp := range matched {
+		color := colorYellow
+		if cp.severity == "high" {
+			color = colorRed
+		}
+		fmt.Printf("  %s  %s (%s)\n", paint(os.Stdout, color, fmt.Sprintf("%-6s", cp.severity)), cp.message, cp.location())
+	}
+	policy, _ := strictestPolicy(matched, policies)
+	fmt.Printf("\nPolicy: %s\n", policy)
+}
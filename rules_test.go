@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeRules(t *testing.T, dir, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, ".ask", rulesFileName)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRulesFileErrors(t *testing.T) {
+	tests := []struct {
+		content, want string
+	}{
+		{"rules:\n  - message: no pattern\n", ":2: rule 1: pattern is required"},
+		{"rules:\n  - pattern: ok\n  - pattern: '(('\n", ":3: rule 2: invalid pattern"},
+		{"rules:\n  - pattern: x\n    severity: low\n", `severity must be "high" or "medium"`},
+		{"rules:\n  - pattern: x\n    sevrity: high\n", `unknown field "sevrity"`},
+		{"rules:\n  - pattern: x\n    env: {STAGE: '['}\n", "invalid env STAGE"},
+		{"rules:\n  - pattern: x\n    dirs: ['[']\n", "invalid dirs entry"},
+		{"danger:\n  - pattern: x\n", `unknown key "danger"`},
+		{"rules: x\n", `"rules" must be a list`},
+		{"policies:\n  high: stop\n", `unknown policy "stop"`},
+		{"policies:\n  low: block\n", `unknown severity "low"`},
+	}
+	for _, tt := range tests {
+		path := writeRules(t, t.TempDir(), tt.content)
+		_, err := loadRulesFile(path)
+		if err == nil || !strings.Contains(err.Error(), tt.want) || !strings.HasPrefix(err.Error(), path) {
+			t.Errorf("loadRulesFile(%q) error = %v, want %q", tt.content, err, tt.want)
+		}
+	}
+}
+
+func TestLoadRulesFileCached(t *testing.T) {
+	path := writeRules(t, t.TempDir(), "rules:\n  - pattern: x\n")
+	first, err := loadRulesFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := loadRulesFile(path); again != first {
+		t.Error("an unchanged rules file should not be parsed again")
+	}
+
+	os.WriteFile(path, []byte("rules:\n  - pattern: x\n  - pattern: y\n"), 0644)
+	changed, err := loadRulesFile(path)
+	if err != nil || len(changed.rules) != 2 {
+		t.Errorf("a changed rules file should be loaded again, got %+v, %v", changed, err)
+	}
+}
+
+func TestUserRulesMerged(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeRules(t, home, "rules:\n  - pattern: '^terraform\\s+apply\\b'\n    unless: '-target\\b'\n    message: terraform apply without -target\n")
+	project := t.TempDir()
+	writeRules(t, project, "rules:\n  - pattern: '^make\\s+deploy\\b'\n    message: Deploys\n    severity: high\n")
+	orig, _ := os.Getwd()
+	os.Chdir(project)
+	defer os.Chdir(orig)
+
+	tests := []struct {
+		cmd, want string
+	}{
+		{"terraform apply", "terraform apply without -target"},
+		{"terraform apply -target=module.db", ""},
+		{"cd infra && make deploy", "Deploys"},
+		{"rm -rf /", "Recursive deletion targeting a broad path"},
+	}
+	for _, tt := range tests {
+		warnings := checkDangerousCommand(tt.cmd)
+		if tt.want == "" && len(warnings) > 0 || tt.want != "" && (len(warnings) == 0 || warnings[0].message != tt.want) {
+			t.Errorf("checkDangerousCommand(%q) = %v, want %q first", tt.cmd, warnings, tt.want)
+		}
+	}
+}
+
+func TestRuleScope(t *testing.T) {
+	repo := t.TempDir()
+	os.MkdirAll(filepath.Join(repo, ".git"), 0755)
+	os.WriteFile(filepath.Join(repo, ".git", "HEAD"), []byte("ref: refs/heads/main\n"), 0644)
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	os.WriteFile(kubeconfig, []byte("apiVersion: v1\ncurrent-context: prod-eu\n"), 0644)
+	t.Setenv("KUBECONFIG", kubeconfig)
+	t.Setenv("STAGE", "production")
+	orig, _ := os.Getwd()
+	os.Chdir(repo)
+	defer os.Chdir(orig)
+
+	path := writeRules(t, t.TempDir(), `rules:
+  - pattern: '^kubectl\s+delete\b'
+    kube_context: '^prod'
+  - pattern: '^git\s+push\b'
+    git_branch: '^main$'
+  - pattern: '^deploy\b'
+    env: {STAGE: prod}
+  - pattern: '^rm\b'
+    dirs: ['/nonexistent/*']
+`)
+	rf, err := loadRulesFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		cmd  string
+		want bool
+	}{
+		{"kubectl delete pod web", true},
+		{"kubectl delete pod web --context staging", false},
+		{"git push origin main", true},
+		{"deploy now", true},
+		{"rm file", false},
+	}
+	for _, tt := range tests {
+		_, matched := matchDangerRules(tt.cmd, rf.rules)
+		if got := len(matched) > 0; got != tt.want {
+			t.Errorf("rules fire for %q = %v, want %v", tt.cmd, got, tt.want)
+		}
+	}
+}
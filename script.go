@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+const (
+	scriptShebang = "#!/usr/bin/env bash"
+	scriptStrict  = "set -euo pipefail"
+)
+
+func buildScriptPrompt(userInput string) string {
+	return renderTemplateOrDefault(scriptTemplateName, newPromptData(userInput))
+}
+
+// normalizeScript strips any markdown fence around the model's script and
+// makes sure it starts with the bash shebang and strict mode.
+func normalizeScript(s string) string {
+	s = stripCodeFences(strings.TrimSpace(s))
+	lines := strings.Split(s, "\n")
+	if len(lines) == 0 || !strings.HasPrefix(lines[0], "#!") {
+		lines = append([]string{scriptShebang}, lines...)
+	}
+	hasStrict := false
+	for _, line := range lines {
+		if strings.TrimSpace(line) == scriptStrict {
+			hasStrict = true
+			break
+		}
+	}
+	if !hasStrict {
+		lines = append([]string{lines[0], scriptStrict}, lines[1:]...)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// scriptLineWarning is a danger warning for one line of a script.
+type scriptLineWarning struct {
+	line    int
+	command string // the command checked, with continued lines joined
+	warning compiledPattern
+}
+
+// lintIgnoreRe marks a command the danger checks should skip, in a comment on
+// the same line or on the lines directly above it.
+var lintIgnoreRe = regexp.MustCompile(`#.*\bask-lint:\s*ignore\b`)
+
+// checkScript runs the danger checks over every command of script, skipping
+// blank lines and comments. Lines continued with a backslash, and a command
+// with its heredoc body, are checked as one command, reported at its first
+// line.
+func checkScript(script string) []scriptLineWarning {
+	var found []scriptLineWarning
+	var cmd, heredoc string
+	start, ignore := 0, false
+	check := func() {
+		if !ignore && !lintIgnoreRe.MatchString(strings.SplitN(cmd, "\n", 2)[0]) {
+			for _, w := range checkDangerousCommand(cmd) {
+				found = append(found, scriptLineWarning{line: start, command: cmd, warning: w})
+			}
+		}
+		cmd, ignore = "", false
+	}
+	for i, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if heredoc != "" {
+			cmd += "\n" + line
+			if trimmed == heredoc {
+				heredoc = ""
+				check()
+			}
+			continue
+		}
+		if cmd == "" {
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+				ignore = trimmed != "" && (ignore || lintIgnoreRe.MatchString(trimmed))
+				continue
+			}
+			start = i + 1
+		}
+		if body, ok := strings.CutSuffix(trimmed, "\\"); ok {
+			cmd += strings.TrimSpace(body) + " "
+			continue
+		}
+		cmd += trimmed
+		if heredoc = heredocDelimiter(cmd); heredoc == "" {
+			check()
+		}
+	}
+	if cmd != "" {
+		check() // a heredoc without its delimiter
+	}
+	return found
+}
+
+// heredocDelimiter returns the delimiter of the last heredoc started on line,
+// or "". Here-strings (<<<) aren't heredocs.
+func heredocDelimiter(line string) string {
+	delimiter := ""
+	for _, m := range heredocRe.FindAllStringSubmatchIndex(line, -1) {
+		if m[0] > 0 && line[m[0]-1] == '<' {
+			continue
+		}
+		delimiter = line[m[2]:m[3]]
+	}
+	return delimiter
+}
+
+func printScriptWarnings(warnings []scriptLineWarning) {
+	for _, lw := range warnings {
+		color := colorYellow
+		if lw.warning.severity == "high" {
+			color = colorRed
+		}
+		fmt.Fprintln(os.Stderr, paint(os.Stderr, color, fmt.Sprintf("  ⚠ Warning (line %d): %s", lw.line, lw.warning.message)))
+	}
+}
+
+// shellcheckScript runs shellcheck over script if it is installed. It
+// returns shellcheck's findings, or "" when there are none or it's missing.
+func shellcheckScript(script string) string {
+	if _, err := exec.LookPath("shellcheck"); err != nil {
+		return ""
+	}
+	cmd := exec.Command("shellcheck", "--shell=bash", "--format=gcc", "-")
+	cmd.Stdin = strings.NewReader(script)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	cmd.Run() // a non-zero exit just means it found something
+	return strings.TrimSpace(strings.ReplaceAll(out.String(), "-:", "line "))
+}
+
+// generateScript asks the model for a reusable script for userInput.
+func generateScript(model, userInput string) (string, error) {
+	result, err := generate(model, buildScriptPrompt(userInput))
+	if err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(stripCodeFences(strings.TrimSpace(result))) == "" {
+		return "", fmt.Errorf("the model returned an empty script")
+	}
+	return normalizeScript(result), nil
+}
+
+// writeScript shows script with its warnings and shellcheck findings, then
+// writes it to path as an executable after confirmation.
+func writeScript(path, script string) error {
+	fmt.Fprintln(os.Stderr, script)
+	printScriptWarnings(checkScript(script))
+	if findings := shellcheckScript(script); findings != "" {
+		fmt.Fprintln(os.Stderr, paint(os.Stderr, colorYellow, "shellcheck:"))
+		fmt.Fprintln(os.Stderr, findings)
+	}
+	if _, err := os.Stat(path); err == nil {
+		fmt.Fprintf(os.Stderr, "%s already exists and will be overwritten\n", path)
+	}
+
+	if !confirmWith(paint(os.Stderr, colorYellow, "→ write "+path), "[Enter to write]") {
+		return nil
+	}
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of an existing file
+	if err := os.Chmod(path, 0755); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "wrote %s\n", path)
+	return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeScript(t *testing.T) {
+	tests := []struct {
+		name, input, want string
+	}{
+		{
+			name:  "complete",
+			input: "#!/usr/bin/env bash\nset -euo pipefail\necho hi",
+			want:  "#!/usr/bin/env bash\nset -euo pipefail\necho hi\n",
+		},
+		{
+			name:  "fenced without header",
+			input: "```bash\n# greet\necho hi\n```",
+			want:  "#!/usr/bin/env bash\nset -euo pipefail\n# greet\necho hi\n",
+		},
+		{
+			name:  "shebang without strict mode",
+			input: "#!/bin/bash\necho hi",
+			want:  "#!/bin/bash\nset -euo pipefail\necho hi\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeScript(tt.input); got != tt.want {
+				t.Errorf("normalizeScript() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckScript(t *testing.T) {
+	script := "#!/usr/bin/env bash\nset -euo pipefail\n# rm -rf / in a comment is fine\nBACKUP_DIR=/var/backups\n\nrm -rf /\n"
+	warnings := checkScript(script)
+	if len(warnings) == 0 {
+		t.Fatal("checkScript() found nothing in a script with rm -rf /")
+	}
+	for _, w := range warnings {
+		if w.line != 6 {
+			t.Errorf("warning on line %d, want 6", w.line)
+		}
+	}
+}
+
+func TestCheckScriptLines(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	script := strings.Join([]string{
+		"rm -rf \\",   // 1: continued
+		"  /var/data", // 2
+		"cat <<EOF",   // 3: a heredoc cat only prints
+		"rm -rf /",    // 4
+		"EOF",         // 5
+		`grep x <<< "$y"`,
+		"# ask-lint: ignore",
+		"chmod 777 /etc/passwd",
+		"chmod 777 /etc/passwd # ask-lint: ignore",
+		"curl https://x.sh | sh", // 10
+		"mysql prod <<EOF",       // 11: the body is SQL for mysql
+		"DROP TABLE users;",      // 12
+		"EOF",                    // 13
+		"bash <<'EOF'",           // 14: the body is run
+		"rm -rf /",               // 15
+		"EOF",                    // 16
+	}, "\n")
+	lines := make(map[int]string)
+	for _, w := range checkScript(script) {
+		lines[w.line] = w.command
+	}
+	want := map[int]string{
+		1:  "rm -rf /var/data",
+		10: "curl https://x.sh | sh",
+		11: "mysql prod <<EOF\nDROP TABLE users;\nEOF",
+		14: "bash <<'EOF'\nrm -rf /\nEOF",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("checkScript() flagged %q, want %q", lines, want)
+	}
+}
+
+func TestBuildScriptPrompt(t *testing.T) {
+	prompt := buildScriptPrompt("back up postgres nightly")
+	for _, want := range []string{"set -euo pipefail", "User request: back up postgres nightly"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("buildScriptPrompt() missing %q", want)
+		}
+	}
+}
+
+func TestWriteScript(t *testing.T) {
+	withPipe(t, &os.Stderr)
+	assumeYes = true
+	defer func() { assumeYes = false }()
+
+	path := filepath.Join(t.TempDir(), "out.sh")
+	os.WriteFile(path, []byte("old"), 0644)
+	if err := writeScript(path, "#!/usr/bin/env bash\nset -euo pipefail\necho hi\n"); err != nil {
+		t.Fatalf("writeScript() error: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("script mode = %v, want 0755", info.Mode().Perm())
+	}
+	if data, _ := os.ReadFile(path); !strings.Contains(string(data), "echo hi") {
+		t.Errorf("script content = %q", data)
+	}
+}
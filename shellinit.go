@@ -0,0 +1,94 @@
+package main
+
+import "fmt"
+
+// initScripts are printed by "ask init SHELL". Each defines a widget bound to
+// Ctrl+G that sends the current command line to "ask --print" and replaces
+// it with the generated command, so it is reviewed, run and recorded in
+// history by the user's own shell. Each also records the last command and its
+// exit status in ~/.ask/last-command for "ask why".
+var initScripts = map[shellDialect]string{
+	dialectZsh: `# ask shell integration for zsh
+# Add to ~/.zshrc:  eval "$(ask init zsh)"
+_ask_widget() {
+  [[ -z "$BUFFER" ]] && return
+  zle -I
+  local cmd
+  cmd=$(command ask --print --shell zsh -- "$BUFFER" </dev/null)
+  if [[ $? -eq 0 && -n "$cmd" ]]; then
+    BUFFER=$cmd
+    CURSOR=${#BUFFER}
+  fi
+  zle reset-prompt
+}
+zle -N _ask_widget
+bindkey '^G' _ask_widget
+
+_ask_preexec() { _ask_last_cmd=$1 }
+_ask_precmd() {
+  local s=$?
+  [[ -z "$_ask_last_cmd" || "$_ask_last_cmd" == "ask why"* ]] && { _ask_last_cmd=; return }
+  mkdir -p ~/.ask && print -r -- "$s"$'\n'"$_ask_last_cmd" >| ~/.ask/last-command
+  _ask_last_cmd=
+}
+autoload -Uz add-zsh-hook
+add-zsh-hook preexec _ask_preexec
+add-zsh-hook precmd _ask_precmd
+`,
+	dialectBash: `# ask shell integration for bash
+# Add to ~/.bashrc:  eval "$(ask init bash)"
+_ask_widget() {
+  [[ -z "$READLINE_LINE" ]] && return
+  local cmd
+  cmd=$(command ask --print --shell bash -- "$READLINE_LINE" </dev/null)
+  if [[ $? -eq 0 && -n "$cmd" ]]; then
+    READLINE_LINE=$cmd
+    READLINE_POINT=${#READLINE_LINE}
+  fi
+}
+bind -x '"\C-g": _ask_widget'
+
+_ask_record_last() {
+  local s=$? line
+  line=$(HISTTIMEFORMAT= builtin history 1)
+  [[ "$line" == "$_ask_last_hist" ]] && return $s
+  _ask_last_hist=$line
+  [[ $line =~ ^\ *[0-9]+\*?\ +(.*)$ ]] || return $s
+  [[ "${BASH_REMATCH[1]}" == "ask why"* ]] && return $s
+  mkdir -p ~/.ask && printf '%s\n%s\n' "$s" "${BASH_REMATCH[1]}" > ~/.ask/last-command
+  return $s
+}
+PROMPT_COMMAND="_ask_record_last${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+`,
+	dialectFish: `# ask shell integration for fish
+# Add to ~/.config/fish/config.fish:  ask init fish | source
+function _ask_widget
+    set -l line (commandline | string collect)
+    test -z "$line"; and return
+    set -l cmd (command ask --print --shell fish -- "$line" </dev/null | string collect)
+    if test -n "$cmd"
+        commandline -r -- $cmd
+        commandline -f end-of-line
+    end
+    commandline -f repaint
+end
+bind \cg _ask_widget
+
+function _ask_postexec --on-event fish_postexec
+    set -l s $status
+    string match -q 'ask why*' -- $argv[1]; and return
+    mkdir -p ~/.ask; and printf '%s\n%s\n' $s $argv[1] > ~/.ask/last-command
+end
+`,
+}
+
+// runInitCommand implements "ask init SHELL".
+func runInitCommand(shell string) error {
+	d, _ := parseDialect(shell)
+	script, ok := initScripts[d]
+	if !ok {
+		return fmt.Errorf("unsupported shell %q (want zsh, bash, or fish)", shell)
+	}
+	fmt.Print(script)
+	return nil
+}
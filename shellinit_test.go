@@ -0,0 +1,43 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestInitScripts(t *testing.T) {
+	for _, d := range []shellDialect{dialectZsh, dialectBash, dialectFish} {
+		script, ok := initScripts[d]
+		if !ok {
+			t.Fatalf("no init script for %s", d)
+		}
+		if !strings.Contains(script, "ask --print --shell "+string(d)) {
+			t.Errorf("%s script should call ask in print mode for its dialect", d)
+		}
+		if !strings.Contains(script, "_ask_widget") {
+			t.Errorf("%s script should define _ask_widget", d)
+		}
+	}
+}
+
+func TestInitScriptSyntax(t *testing.T) {
+	for _, d := range []shellDialect{dialectZsh, dialectBash, dialectFish} {
+		if _, err := exec.LookPath(string(d)); err != nil {
+			continue
+		}
+		c := exec.Command(string(d), "-n")
+		c.Stdin = strings.NewReader(initScripts[d])
+		if out, err := c.CombinedOutput(); err != nil {
+			t.Errorf("%s -n failed: %v\n%s", d, err, out)
+		}
+	}
+}
+
+func TestRunInitCommandUnsupported(t *testing.T) {
+	for _, shell := range []string{"tcsh", "sh", ""} {
+		if err := runInitCommand(shell); err == nil {
+			t.Errorf("runInitCommand(%q) should fail", shell)
+		}
+	}
+}
@@ -0,0 +1,779 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// This file is a small parser for the parts of POSIX shell syntax ask needs
+// to reason about commands: lists, pipelines, simple commands, quoting,
+// redirections, subshells, brace groups, function definitions and command
+// substitutions. It never evaluates anything and accepts some input a real
+// shell would reject; it only has to recover the structure of a command.
+
+// shellWord is one word of a command.
+type shellWord struct {
+	Raw    string   // as written, quotes included
+	Value  string   // quotes and escapes removed; expansions kept as written
+	Quoted bool     // some part of it was quoted or escaped
+	Substs []string // source of each $(...), `...`, <(...) or >(...) in it
+}
+
+// shellRedirect is a redirection such as "2>&1", "> out.txt" or "<<EOF".
+type shellRedirect struct {
+	Op      string    // as written, including any fd: "2>", ">>", "<<"
+	Target  shellWord // the file, fd or heredoc delimiter
+	Heredoc string    // the body of a heredoc
+}
+
+func (r shellRedirect) String() string {
+	return r.Op + r.Target.Raw
+}
+
+// simpleCommand is one command of a pipeline: either a simple command, or a
+// subshell, brace group or function definition with its body in Group.
+type simpleCommand struct {
+	Keyword   string // reserved words before it ("if", "do", "for f in *"), if any
+	Assigns   []shellWord
+	Words     []shellWord // the command name and its arguments
+	Redirects []shellRedirect
+	Group     *shellList // body of ( ... ), { ... } or a function
+	Subshell  bool       // Group is a ( ... ) subshell
+	Func      string     // the name of the function this defines, if any
+	Raw       string     // the source text of the command
+}
+
+// name returns the command name, or "" when there is none.
+func (c *simpleCommand) name() string {
+	if len(c.Words) == 0 {
+		return ""
+	}
+	return c.Words[0].Value
+}
+
+// args returns the values of the command's arguments.
+func (c *simpleCommand) args() []string {
+	var args []string
+	for _, w := range c.Words[min(1, len(c.Words)):] {
+		args = append(args, w.Value)
+	}
+	return args
+}
+
+// shellPipeline is one or more commands joined by "|".
+type shellPipeline struct {
+	Commands []*simpleCommand
+	Negated  bool
+	Op       string // the operator after it: "&&", "||", ";", "&", or "" at the end
+}
+
+// shellList is a sequence of pipelines.
+type shellList struct {
+	Pipelines []*shellPipeline
+}
+
+// commands returns every command in the list in source order, including
+// those inside subshells, groups and function bodies (after the command that
+// contains them). Command substitutions are not included.
+func (l *shellList) commands() []*simpleCommand {
+	var cmds []*simpleCommand
+	if l == nil {
+		return nil
+	}
+	for _, pl := range l.Pipelines {
+		for _, c := range pl.Commands {
+			cmds = append(cmds, c)
+			cmds = append(cmds, c.Group.commands()...)
+		}
+	}
+	return cmds
+}
+
+type shellTokenKind int
+
+const (
+	tokWord shellTokenKind = iota
+	tokOp
+	tokNewline
+	tokEOF
+)
+
+type shellToken struct {
+	kind     shellTokenKind
+	op       string    // operator, for tokOp
+	word     shellWord // for tokWord
+	heredoc  string    // heredoc body, for the delimiter word of a heredoc
+	pos, end int       // byte offsets in the source
+}
+
+// shellOps lists the operators, longest first so that the first match wins.
+var shellOps = []string{
+	"&>>", "<<<", "<<-",
+	"&&", "||", ";;", "|&", "<<", ">>", ">|", ">&", "<&", "<>", "&>",
+	";", "&", "|", "(", ")", "<", ">",
+}
+
+func shellOpAt(src string, i int) string {
+	for _, op := range shellOps {
+		if strings.HasPrefix(src[i:], op) {
+			return op
+		}
+	}
+	return ""
+}
+
+func isRedirectOp(op string) bool {
+	return strings.ContainsAny(op, "<>")
+}
+
+func isHeredocOp(t shellToken) bool {
+	op := strings.TrimLeft(t.op, "0123456789")
+	return t.kind == tokOp && (op == "<<" || op == "<<-")
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// lexShell splits src into words, operators and newlines.
+func lexShell(src string) ([]shellToken, error) {
+	var toks []shellToken
+	var heredocs []int // delimiter tokens whose body starts after the next newline
+	i := 0
+	for {
+		for i < len(src) {
+			if src[i] == ' ' || src[i] == '\t' || src[i] == '\r' {
+				i++
+			} else if strings.HasPrefix(src[i:], "\\\n") {
+				i += 2
+			} else {
+				break
+			}
+		}
+		if i >= len(src) {
+			return append(toks, shellToken{kind: tokEOF, pos: i, end: i}), nil
+		}
+
+		switch c := src[i]; {
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			continue
+		case c == '\n':
+			toks = append(toks, shellToken{kind: tokNewline, pos: i, end: i + 1})
+			i++
+			for _, idx := range heredocs {
+				strip := strings.HasSuffix(toks[idx-1].op, "<<-")
+				toks[idx].heredoc, i = readHeredoc(src, i, toks[idx].word.Value, strip)
+			}
+			heredocs = nil
+			continue
+		}
+
+		// An fd number directly before a redirection belongs to it: 2>&1
+		j := i
+		for j < len(src) && isDigit(src[j]) {
+			j++
+		}
+		if j < len(src) && !strings.HasPrefix(src[j:], "<(") && !strings.HasPrefix(src[j:], ">(") {
+			if op := shellOpAt(src, j); op != "" && (j == i || isRedirectOp(op)) {
+				toks = append(toks, shellToken{kind: tokOp, op: src[i:j] + op, pos: i, end: j + len(op)})
+				i = j + len(op)
+				continue
+			}
+		}
+
+		w, end, err := lexWord(src, i)
+		if err != nil {
+			return nil, err
+		}
+		if n := len(toks); n > 0 && isHeredocOp(toks[n-1]) {
+			heredocs = append(heredocs, n)
+		}
+		toks = append(toks, shellToken{kind: tokWord, word: w, pos: i, end: end})
+		i = end
+	}
+}
+
+// lexWord reads the word starting at src[i] and returns it with the offset
+// just past it.
+func lexWord(src string, i int) (shellWord, int, error) {
+	start := i
+	var w shellWord
+	var val strings.Builder
+	for i < len(src) {
+		c := src[i]
+		if strings.IndexByte(" \t\r\n;&|()<>", c) >= 0 {
+			if (c == '<' || c == '>') && i+1 < len(src) && src[i+1] == '(' {
+				end := matchParen(src, i+2)
+				if end < 0 {
+					return w, i, fmt.Errorf("unterminated %c(", c)
+				}
+				w.Substs = append(w.Substs, src[i+2:end])
+				val.WriteString(src[i : end+1])
+				i = end + 1
+				continue
+			}
+			break
+		}
+
+		switch {
+		case c == '\\':
+			if i+1 >= len(src) {
+				val.WriteByte(c)
+				i++
+				continue
+			}
+			if src[i+1] != '\n' {
+				val.WriteByte(src[i+1])
+			}
+			w.Quoted = true
+			i += 2
+		case c == '\'':
+			end := strings.IndexByte(src[i+1:], '\'')
+			if end < 0 {
+				return w, i, fmt.Errorf("unterminated single quote")
+			}
+			val.WriteString(src[i+1 : i+1+end])
+			w.Quoted = true
+			i += end + 2
+		case c == '"':
+			end, value, substs := readDouble(src, i+1)
+			if end < 0 {
+				return w, i, fmt.Errorf("unterminated double quote")
+			}
+			val.WriteString(value)
+			w.Substs = append(w.Substs, substs...)
+			w.Quoted = true
+			i = end + 1
+		case c == '`':
+			end := matchBacktick(src, i+1)
+			if end < 0 {
+				return w, i, fmt.Errorf("unterminated backquote")
+			}
+			w.Substs = append(w.Substs, src[i+1:end])
+			val.WriteString(src[i : end+1])
+			i = end + 1
+		case c == '$' && i+1 < len(src) && src[i+1] == '\'':
+			end := i + 2
+			for end < len(src) && src[end] != '\'' {
+				if src[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(src) {
+				return w, i, fmt.Errorf("unterminated $' quote")
+			}
+			val.WriteString(ansiCUnescaper.Replace(src[i+2 : end]))
+			w.Quoted = true
+			i = end + 1
+		case c == '$' && i+1 < len(src) && src[i+1] == '(':
+			end := matchParen(src, i+2)
+			if end < 0 {
+				return w, i, fmt.Errorf("unterminated $(")
+			}
+			if !strings.HasPrefix(src[i:], "$((") {
+				w.Substs = append(w.Substs, src[i+2:end])
+			}
+			val.WriteString(src[i : end+1])
+			i = end + 1
+		case c == '$' && i+1 < len(src) && src[i+1] == '{':
+			end := matchBrace(src, i+2)
+			if end < 0 {
+				return w, i, fmt.Errorf("unterminated ${")
+			}
+			val.WriteString(src[i : end+1])
+			i = end + 1
+		default:
+			val.WriteByte(c)
+			i++
+		}
+	}
+	w.Raw = src[start:i]
+	w.Value = val.String()
+	return w, i, nil
+}
+
+var ansiCUnescaper = strings.NewReplacer(`\n`, "\n", `\t`, "\t", `\'`, "'", `\"`, `"`, `\\`, `\`)
+
+// readDouble reads a double-quoted string whose content starts at src[i]. It
+// returns the offset of the closing quote (-1 if there is none), the content
+// with escapes resolved, and any command substitutions in it.
+func readDouble(src string, i int) (int, string, []string) {
+	var val strings.Builder
+	var substs []string
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == '"':
+			return i, val.String(), substs
+		case c == '\\' && i+1 < len(src):
+			if next := src[i+1]; strings.IndexByte("$`\"\\", next) >= 0 {
+				val.WriteByte(next)
+			} else if next != '\n' {
+				val.WriteString(src[i : i+2])
+			}
+			i += 2
+		case c == '`':
+			end := matchBacktick(src, i+1)
+			if end < 0 {
+				return -1, "", nil
+			}
+			substs = append(substs, src[i+1:end])
+			val.WriteString(src[i : end+1])
+			i = end + 1
+		case c == '$' && i+1 < len(src) && (src[i+1] == '(' || src[i+1] == '{'):
+			var end int
+			if src[i+1] == '(' {
+				end = matchParen(src, i+2)
+			} else {
+				end = matchBrace(src, i+2)
+			}
+			if end < 0 {
+				return -1, "", nil
+			}
+			if src[i+1] == '(' && !strings.HasPrefix(src[i:], "$((") {
+				substs = append(substs, src[i+2:end])
+			}
+			val.WriteString(src[i : end+1])
+			i = end + 1
+		default:
+			val.WriteByte(c)
+			i++
+		}
+	}
+	return -1, "", nil
+}
+
+// matchParen returns the offset of the ")" closing a "(" just before src[i],
+// skipping quoted text, or -1.
+func matchParen(src string, i int) int {
+	return matchClosing(src, i, '(', ')')
+}
+
+// matchBrace is matchParen for "${ ... }".
+func matchBrace(src string, i int) int {
+	return matchClosing(src, i, '{', '}')
+}
+
+func matchClosing(src string, i int, open, close byte) int {
+	depth := 1
+	for ; i < len(src); i++ {
+		switch c := src[i]; c {
+		case '\\':
+			i++
+		case '\'':
+			end := strings.IndexByte(src[i+1:], '\'')
+			if end < 0 {
+				return -1
+			}
+			i += end + 1
+		case '"':
+			end, _, _ := readDouble(src, i+1)
+			if end < 0 {
+				return -1
+			}
+			i = end
+		case '`':
+			end := matchBacktick(src, i+1)
+			if end < 0 {
+				return -1
+			}
+			i = end
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// matchBacktick returns the offset of the backquote closing one just before
+// src[i], or -1.
+func matchBacktick(src string, i int) int {
+	for ; i < len(src); i++ {
+		switch src[i] {
+		case '\\':
+			i++
+		case '`':
+			return i
+		}
+	}
+	return -1
+}
+
+// readHeredoc reads heredoc lines from src[i] up to the delimiter line and
+// returns the body and the offset after the delimiter.
+func readHeredoc(src string, i int, delim string, stripTabs bool) (string, int) {
+	var body strings.Builder
+	for i < len(src) {
+		end := strings.IndexByte(src[i:], '\n')
+		next := i + end + 1
+		if end < 0 {
+			end, next = len(src)-i, len(src)
+		}
+		line := src[i : i+end]
+		if stripTabs {
+			line = strings.TrimLeft(line, "\t")
+		}
+		i = next
+		if line == delim {
+			break
+		}
+		body.WriteString(line + "\n")
+	}
+	return body.String(), i
+}
+
+// assignRe matches a variable assignment word: NAME=, NAME+=, NAME[i]=
+var assignRe = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\[[^]]*\])?\+?=`)
+
+type shellParser struct {
+	src       string
+	toks      []shellToken
+	i         int
+	caseDepth int
+}
+
+// parseShell parses src into a list of pipelines.
+func parseShell(src string) (*shellList, error) {
+	toks, err := lexShell(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &shellParser{src: src, toks: toks}
+	list, err := p.parseList("")
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q", p.text(t))
+	}
+	return list, nil
+}
+
+func (p *shellParser) peek() shellToken {
+	return p.toks[p.i]
+}
+
+func (p *shellParser) peekAt(n int) shellToken {
+	if p.i+n >= len(p.toks) {
+		return p.toks[len(p.toks)-1]
+	}
+	return p.toks[p.i+n]
+}
+
+func (p *shellParser) text(t shellToken) string {
+	if t.kind == tokNewline {
+		return "newline"
+	}
+	return p.src[t.pos:t.end]
+}
+
+func isOpToken(t shellToken, op string) bool {
+	return t.kind == tokOp && t.op == op
+}
+
+// isReserved reports whether t is the unquoted reserved word w.
+func isReserved(t shellToken, w string) bool {
+	return t.kind == tokWord && !t.word.Quoted && t.word.Raw == w
+}
+
+func (p *shellParser) skipNewlines() {
+	for p.peek().kind == tokNewline {
+		p.i++
+	}
+}
+
+// parseList parses pipelines up to EOF or closer (")" or "}").
+func (p *shellParser) parseList(closer string) (*shellList, error) {
+	list := &shellList{}
+	for {
+		p.skipNewlines()
+		t := p.peek()
+		if t.kind == tokEOF || closer == ")" && isOpToken(t, ")") || closer == "}" && isReserved(t, "}") {
+			return list, nil
+		}
+		if isOpToken(t, ")") {
+			return nil, fmt.Errorf("unexpected %q", ")")
+		}
+
+		pl, err := p.parsePipeline()
+		if err != nil {
+			return nil, err
+		}
+		switch t := p.peek(); {
+		case t.kind == tokOp && (t.op == "&&" || t.op == "||" || t.op == ";" || t.op == "&"):
+			pl.Op = t.op
+			p.i++
+		case isOpToken(t, ";;"), t.kind == tokNewline:
+			pl.Op = ";"
+			p.i++
+		case t.kind == tokEOF, isOpToken(t, ")"), closer == "}" && isReserved(t, "}"):
+		default:
+			return nil, fmt.Errorf("unexpected %q", p.text(t))
+		}
+
+		if len(pl.Commands) > 0 {
+			list.Pipelines = append(list.Pipelines, pl)
+		} else if n := len(list.Pipelines); n > 0 && pl.Op != "" && pl.Op != ";" {
+			// "fi && next": the operator joins what came before
+			list.Pipelines[n-1].Op = pl.Op
+		}
+	}
+}
+
+func (p *shellParser) parsePipeline() (*shellPipeline, error) {
+	pl := &shellPipeline{}
+	if isReserved(p.peek(), "!") {
+		pl.Negated = true
+		p.i++
+	}
+	for {
+		cmd, err := p.parseCommand()
+		if err != nil {
+			return nil, err
+		}
+		if cmd != nil {
+			pl.Commands = append(pl.Commands, cmd)
+		}
+		if t := p.peek(); !isOpToken(t, "|") && !isOpToken(t, "|&") {
+			return pl, nil
+		}
+		p.i++
+		p.skipNewlines()
+	}
+}
+
+// parseCommand parses one command, or returns nil when there is only a
+// closing reserved word like "fi" or "done".
+func (p *shellParser) parseCommand() (*simpleCommand, error) {
+	cmd := &simpleCommand{}
+	start := p.peek().pos
+	var keywords []string
+	header := false
+	if pattern := p.skipCasePattern(); pattern != "" {
+		keywords = append(keywords, pattern)
+	}
+
+keywords:
+	for {
+		t := p.peek()
+		if t.kind != tokWord || t.word.Quoted {
+			break
+		}
+		switch t.word.Raw {
+		case "if", "then", "else", "elif", "do", "while", "until", "time", "!", "fi", "done":
+			keywords = append(keywords, t.word.Raw)
+			p.i++
+		case "esac":
+			if p.caseDepth > 0 {
+				p.caseDepth--
+			}
+			keywords = append(keywords, t.word.Raw)
+			p.i++
+		case "for", "select", "case":
+			keywords = append(keywords, p.parseHeader())
+			header = true
+			if t.word.Raw != "case" {
+				break keywords
+			}
+			p.caseDepth++
+			p.skipNewlines()
+			if pattern := p.skipCasePattern(); pattern != "" {
+				keywords = append(keywords, pattern)
+			}
+		case "function":
+			p.i++
+			if p.peek().kind != tokWord {
+				return nil, fmt.Errorf("expected a function name")
+			}
+			name := p.peek().word.Value
+			p.i++
+			if isOpToken(p.peek(), "(") && isOpToken(p.peekAt(1), ")") {
+				p.i += 2
+			}
+			cmd.Keyword = strings.Join(keywords, " ")
+			return p.parseFunction(cmd, name, start)
+		default:
+			break keywords
+		}
+	}
+	cmd.Keyword = strings.Join(keywords, " ")
+
+	if t := p.peek(); isOpToken(t, "(") || isReserved(t, "{") {
+		if err := p.parseCompound(cmd); err != nil {
+			return nil, err
+		}
+		cmd.Raw = strings.TrimSpace(p.src[start:p.toks[p.i-1].end])
+		return cmd, nil
+	}
+
+	for {
+		t := p.peek()
+		if t.kind == tokWord {
+			p.i++
+			if len(cmd.Words) == 0 && assignRe.MatchString(t.word.Raw) {
+				cmd.Assigns = append(cmd.Assigns, p.arrayAssignment(t))
+				continue
+			}
+			cmd.Words = append(cmd.Words, t.word)
+			if len(cmd.Words) == 1 && isOpToken(p.peek(), "(") && isOpToken(p.peekAt(1), ")") {
+				p.i += 2
+				cmd.Words = nil
+				return p.parseFunction(cmd, t.word.Value, start)
+			}
+			continue
+		}
+		if t.kind == tokOp && isRedirectOp(t.op) {
+			if err := p.parseRedirect(cmd); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		break
+	}
+
+	if len(cmd.Words) == 0 && len(cmd.Assigns) == 0 && len(cmd.Redirects) == 0 && !header {
+		return nil, nil
+	}
+	cmd.Raw = strings.TrimSpace(p.src[start:p.toks[p.i-1].end])
+	return cmd, nil
+}
+
+// parseHeader consumes the header of a for, select or case command ("for f
+// in *.log", "case $x in") and returns its text.
+func (p *shellParser) parseHeader() string {
+	start := p.peek().pos
+	isCase := p.peek().word.Raw == "case"
+	p.i++
+	depth := 0 // for (( ... )) loops
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokWord:
+			p.i++
+			if isCase && depth == 0 && isReserved(t, "in") {
+				return p.src[start:t.end]
+			}
+			continue
+		case isOpToken(t, "("):
+			depth++
+			p.i++
+			continue
+		case depth > 0 && t.kind != tokEOF:
+			// inside (( ... )), < and > compare and ; separates, so
+			// operators are part of the header
+			if isOpToken(t, ")") {
+				depth--
+			}
+			p.i++
+			continue
+		}
+		return strings.TrimSpace(p.src[start:p.toks[p.i-1].end])
+	}
+}
+
+// skipCasePattern consumes a case pattern like "a|b)" or "(*)" when parsing
+// inside a case command, and returns its text.
+func (p *shellParser) skipCasePattern() string {
+	if p.caseDepth == 0 {
+		return ""
+	}
+	j := p.i
+	if isOpToken(p.toks[j], "(") {
+		j++
+	}
+	for {
+		if p.toks[j].kind != tokWord || isReserved(p.toks[j], "esac") {
+			return ""
+		}
+		j++
+		if isOpToken(p.toks[j], ")") {
+			break
+		}
+		if !isOpToken(p.toks[j], "|") {
+			return ""
+		}
+		j++
+	}
+	text := p.src[p.toks[p.i].pos:p.toks[j].end]
+	p.i = j + 1
+	return text
+}
+
+// arrayAssignment completes an assignment word like "arr=(a b c)".
+func (p *shellParser) arrayAssignment(t shellToken) shellWord {
+	w := t.word
+	if !strings.HasSuffix(w.Raw, "=") || !isOpToken(p.peek(), "(") || p.peek().pos != t.end {
+		return w
+	}
+	p.i++
+	for p.peek().kind == tokWord || p.peek().kind == tokNewline {
+		p.i++
+	}
+	if isOpToken(p.peek(), ")") {
+		p.i++
+	}
+	w.Raw = p.src[t.pos:p.toks[p.i-1].end]
+	w.Value = w.Raw
+	return w
+}
+
+func (p *shellParser) parseRedirect(cmd *simpleCommand) error {
+	op := p.peek().op
+	p.i++
+	t := p.peek()
+	if t.kind != tokWord {
+		return fmt.Errorf("expected a file name after %s", op)
+	}
+	p.i++
+	cmd.Redirects = append(cmd.Redirects, shellRedirect{Op: op, Target: t.word, Heredoc: t.heredoc})
+	return nil
+}
+
+// parseCompound parses a ( ... ) subshell or { ... } group into cmd, with
+// any redirections after it.
+func (p *shellParser) parseCompound(cmd *simpleCommand) error {
+	closer := "}"
+	if isOpToken(p.peek(), "(") {
+		closer = ")"
+		cmd.Subshell = true
+	}
+	p.i++
+	group, err := p.parseList(closer)
+	if err != nil {
+		return err
+	}
+	if t := p.peek(); !(isOpToken(t, closer) || isReserved(t, closer)) {
+		return fmt.Errorf("missing %q", closer)
+	}
+	p.i++
+	cmd.Group = group
+	for t := p.peek(); t.kind == tokOp && isRedirectOp(t.op); t = p.peek() {
+		if err := p.parseRedirect(cmd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *shellParser) parseFunction(cmd *simpleCommand, name string, start int) (*simpleCommand, error) {
+	p.skipNewlines()
+	if t := p.peek(); !isOpToken(t, "(") && !isReserved(t, "{") {
+		return nil, fmt.Errorf("expected a body for function %s", name)
+	}
+	if err := p.parseCompound(cmd); err != nil {
+		return nil, err
+	}
+	cmd.Func = name
+	cmd.Raw = strings.TrimSpace(p.src[start:p.toks[p.i-1].end])
+	return cmd, nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// commandNames returns "name args..." for every command parsed from src.
+func commandNames(t *testing.T, src string) []string {
+	t.Helper()
+	list, err := parseShell(src)
+	if err != nil {
+		t.Fatalf("parseShell(%q) error: %v", src, err)
+	}
+	var names []string
+	for _, c := range list.commands() {
+		switch {
+		case c.Func != "":
+			names = append(names, c.Func+"()")
+		case c.Group != nil:
+			names = append(names, "group")
+		default:
+			names = append(names, strings.TrimSpace(c.name()+" "+strings.Join(c.args(), " ")))
+		}
+	}
+	return names
+}
+
+func TestParseShellCommands(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{`ls -la`, []string{"ls -la"}},
+		{`echo "rm -rf /"`, []string{"echo rm -rf /"}},
+		{`find . -name '*.go' | xargs wc -l`, []string{"find . -name *.go", "xargs wc -l"}},
+		{`make && make install || echo failed; ls &`, []string{"make", "make install", "echo failed", "ls"}},
+		{`cd /tmp && (rm -rf build; mkdir build)`, []string{"cd /tmp", "group", "rm -rf build", "mkdir build"}},
+		{`{ echo a; echo b; } > out`, []string{"group", "echo a", "echo b"}},
+		{`FOO=1 BAR="x y" env`, []string{"env"}},
+		{`for f in *.log; do gzip "$f"; done`, []string{"", "gzip $f"}},
+		{`for ((i=0;i<3;i++)); do echo $i; done`, []string{"", "echo $i"}},
+		{`if [ -f x ]; then rm x; else echo none; fi`, []string{"[ -f x ]", "rm x", "echo none"}},
+		{"case $1 in\n  start) run;;\n  a|b) stop ;;\nesac", []string{"run", "stop"}},
+		{`deploy() { build && upload; }`, []string{"deploy()", "build", "upload"}},
+		{`:(){ :|:& };:`, []string{":()", ":", ":", ":"}},
+		{"cat <<EOF > conf\nrm -rf /\nEOF\necho done", []string{"cat", "echo done"}},
+		{`echo it\'s "a \"b\"" $'c\td'`, []string{"echo it's a \"b\" c\td"}},
+		{"docker run \\\n  --rm image # comment", []string{"docker run --rm image"}},
+		{`diff <(sort a) <(sort b)`, []string{"diff <(sort a) <(sort b)"}},
+		{`arr=(a b c); echo ${arr[0]}`, []string{"", "echo ${arr[0]}"}},
+	}
+	for _, tt := range tests {
+		if got := commandNames(t, tt.src); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseShell(%q) commands = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseShellStructure(t *testing.T) {
+	list, err := parseShell(`! grep -q x file 2>/dev/null | tee log >> all.log && FOO=1 make`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Pipelines) != 2 {
+		t.Fatalf("got %d pipelines, want 2", len(list.Pipelines))
+	}
+	first := list.Pipelines[0]
+	if !first.Negated || first.Op != "&&" || len(first.Commands) != 2 {
+		t.Errorf("first pipeline = %+v", first)
+	}
+	grep := first.Commands[0]
+	if len(grep.Redirects) != 1 || grep.Redirects[0].String() != "2>/dev/null" {
+		t.Errorf("grep redirects = %+v", grep.Redirects)
+	}
+	if grep.Raw != "grep -q x file 2>/dev/null" {
+		t.Errorf("grep Raw = %q", grep.Raw)
+	}
+	if tee := first.Commands[1]; len(tee.Redirects) != 1 || tee.Redirects[0].Op != ">>" || tee.Redirects[0].Target.Value != "all.log" {
+		t.Errorf("tee redirects = %+v", tee.Redirects)
+	}
+	if make := list.Pipelines[1].Commands[0]; len(make.Assigns) != 1 || make.Assigns[0].Raw != "FOO=1" {
+		t.Errorf("make assigns = %+v", make.Assigns)
+	}
+}
+
+func TestParseShellWords(t *testing.T) {
+	list, err := parseShell("echo \"today: $(date +%F)\" `whoami` && cat <<'EOF'\nhello $USER\nEOF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	echo := list.Pipelines[0].Commands[0]
+	if got := echo.Words[1].Substs; !reflect.DeepEqual(got, []string{"date +%F"}) {
+		t.Errorf("Substs = %q", got)
+	}
+	if !echo.Words[1].Quoted || echo.Words[1].Value != "today: $(date +%F)" {
+		t.Errorf("word = %+v", echo.Words[1])
+	}
+	if got := echo.Words[2].Substs; !reflect.DeepEqual(got, []string{"whoami"}) {
+		t.Errorf("backquote Substs = %q", got)
+	}
+	cat := list.Pipelines[1].Commands[0]
+	if len(cat.Redirects) != 1 || cat.Redirects[0].Heredoc != "hello $USER\n" {
+		t.Errorf("heredoc = %+v", cat.Redirects)
+	}
+}
+
+func TestParseShellErrors(t *testing.T) {
+	for _, src := range []string{`echo 'oops`, `echo "oops`, `echo $(date`, `(ls`, `{ ls;`, `ls >`, `ls )`} {
+		if _, err := parseShell(src); err == nil {
+			t.Errorf("parseShell(%q) should fail", src)
+		}
+	}
+}
@@ -10,21 +10,28 @@ import (
 var spinnerFrames = []string{"⠋", "⠙", "⠹", "⠸", "⠼", "⠴", "⠦", "⠧", "⠇", "⠏"}
 
 type Spinner struct {
-	message string
-	stop    chan struct{}
-	done    chan struct{}
-	mu      sync.Mutex
+	message  string
+	stop     chan struct{}
+	done     chan struct{}
+	mu       sync.Mutex
+	disabled bool
 }
 
+// NewSpinner returns a spinner that draws on stderr. It does nothing when
+// stderr isn't a terminal, so piped and CI output stays free of escapes.
 func NewSpinner(message string) *Spinner {
 	return &Spinner{
-		message: message,
-		stop:    make(chan struct{}),
-		done:    make(chan struct{}),
+		message:  message,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		disabled: !isTerminal(os.Stderr),
 	}
 }
 
 func (s *Spinner) Start() {
+	if s.disabled {
+		return
+	}
 	go func() {
 		defer close(s.done)
 		i := 0
@@ -38,7 +45,8 @@ func (s *Spinner) Start() {
 				s.mu.Lock()
 				msg := s.message
 				s.mu.Unlock()
-				fmt.Fprintf(os.Stderr, "\r\033[36m%s\033[0m %s", spinnerFrames[i%len(spinnerFrames)], msg)
+				frame := paint(os.Stderr, colorCyan, spinnerFrames[i%len(spinnerFrames)])
+				fmt.Fprintf(os.Stderr, "\r%s %s", frame, msg)
 				i++
 				time.Sleep(80 * time.Millisecond)
 			}
@@ -47,6 +55,9 @@ func (s *Spinner) Start() {
 }
 
 func (s *Spinner) Stop() {
+	if s.disabled {
+		return
+	}
 	close(s.stop)
 	<-s.done
 }
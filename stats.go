@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -26,11 +27,13 @@ type Counters struct {
 
 type HistoryEntry struct {
 	Timestamp time.Time `json:"timestamp"`
-	Mode      string    `json:"mode"` // "oneshot", "interactive", "explain"
+	Mode      string    `json:"mode"` // "oneshot", "interactive", "explain", "plan", "script"
 	Model     string    `json:"model"`
 	Query     string    `json:"query"`
 	Command   string    `json:"command,omitempty"`
 	Executed  bool      `json:"executed"`
+	// Assessment is the model's risk assessment of Command, with --assess.
+	Assessment *riskAssessment `json:"assessment,omitempty"`
 }
 
 type Stats struct {
@@ -153,6 +156,28 @@ func (s *Stats) RecordInteractiveCommand(model, query, command string) {
 	})
 }
 
+func (s *Stats) RecordPlan(model, query string, steps []string) {
+	s.recordGenerated("plan", model, query, strings.Join(steps, "\n"))
+}
+
+func (s *Stats) RecordScript(model, query, script string) {
+	s.recordGenerated("script", model, query, script)
+}
+
+func (s *Stats) recordGenerated(mode, model, query, command string) {
+	s.Counters.CommandsGenerated++
+	s.Models[model]++
+
+	s.History = append(s.History, HistoryEntry{
+		Timestamp: time.Now(),
+		Mode:      mode,
+		Model:     model,
+		Query:     truncateString(query, 100),
+		Command:   truncateString(command, 200),
+		Executed:  false,
+	})
+}
+
 func (s *Stats) RecordExecution() {
 	s.Counters.CommandsExecuted++
 	// Mark last history entry as executed
@@ -161,6 +186,13 @@ func (s *Stats) RecordExecution() {
 	}
 }
 
+// RecordAssessment attaches a risk assessment to the last history entry.
+func (s *Stats) RecordAssessment(a *riskAssessment) {
+	if len(s.History) > 0 {
+		s.History[len(s.History)-1].Assessment = a
+	}
+}
+
 func (s *Stats) RecordExplain(model string) {
 	s.Counters.ExplainCalls++
 	s.Models[model]++
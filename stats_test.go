@@ -129,6 +129,26 @@ func TestStats_RecordOneshotCommand(t *testing.T) {
 	}
 }
 
+func TestStats_RecordPlan(t *testing.T) {
+	stats := &Stats{
+		Version: statsVersion,
+		Models:  make(map[string]int),
+		History: []HistoryEntry{},
+	}
+
+	stats.RecordPlan("llama3", "release", []string{"git tag v1", "git push --tags"})
+
+	if stats.Counters.CommandsGenerated != 1 {
+		t.Errorf("expected 1 command generated, got %d", stats.Counters.CommandsGenerated)
+	}
+	if len(stats.History) != 1 || stats.History[0].Mode != "plan" {
+		t.Fatalf("expected 1 'plan' history entry, got %+v", stats.History)
+	}
+	if stats.History[0].Command != "git tag v1\ngit push --tags" {
+		t.Errorf("expected steps joined by newlines, got %q", stats.History[0].Command)
+	}
+}
+
 func TestStats_RecordExecution(t *testing.T) {
 	stats := &Stats{
 		Version: statsVersion,
@@ -148,6 +168,27 @@ func TestStats_RecordExecution(t *testing.T) {
 	}
 }
 
+func TestStats_RecordAssessment(t *testing.T) {
+	stats := &Stats{
+		Version: statsVersion,
+		Models:  make(map[string]int),
+		History: []HistoryEntry{
+			{Mode: "oneshot", Command: "kubectl scale deploy/web --replicas=0"},
+		},
+	}
+
+	stats.RecordAssessment(&riskAssessment{Risk: "high", Reversible: "partly", Reason: "takes the service down"})
+
+	data, _ := json.Marshal(stats.History[0])
+	var entry HistoryEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry.Assessment == nil || entry.Assessment.Risk != "high" || entry.Assessment.Reason != "takes the service down" {
+		t.Errorf("assessment not stored in history: %s", data)
+	}
+}
+
 func TestStats_SizeTruncation(t *testing.T) {
 	tmpDir := t.TempDir()
 	origHome := os.Getenv("HOME")
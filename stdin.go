@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	// maxPipedBytes caps how much piped input is kept to feed the generated command.
+	maxPipedBytes = 10 * 1024 * 1024
+	// pipedSampleHead and pipedSampleTail bound the part of it shown to the model.
+	pipedSampleHead = 3000
+	pipedSampleTail = 1000
+)
+
+// pipedInput holds data piped into a one-shot invocation. A generated command
+// receives it on stdin when run.
+var pipedInput []byte
+
+// readPipedInput reads up to max bytes from r, reporting whether more remained.
+func readPipedInput(r io.Reader, max int) ([]byte, bool, error) {
+	data, err := io.ReadAll(io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(data) > max {
+		return data[:max], true, nil
+	}
+	return data, false, nil
+}
+
+// sampleInput returns the start and end of data, eliding the middle when it
+// is too long to include in a prompt.
+func sampleInput(data []byte) string {
+	s := string(data)
+	if len(s) <= pipedSampleHead+pipedSampleTail {
+		return strings.TrimRight(s, "\n")
+	}
+	omitted := len(s) - pipedSampleHead - pipedSampleTail
+	return fmt.Sprintf("%s\n... [%d bytes omitted] ...\n%s",
+		s[:pipedSampleHead], omitted, strings.TrimRight(s[len(s)-pipedSampleTail:], "\n"))
+}
+
+// diagnoseQueryRe matches requests that ask about a failure. The piped data
+// itself doesn't decide: "count requests per endpoint" over an error-heavy log
+// still wants a command.
+var diagnoseQueryRe = regexp.MustCompile(`(?i)^\s*(why|fix|what went wrong|what does this (error )?mean|diagnose)\b`)
+
+// wantsDiagnosis decides whether piped input should be diagnosed rather than
+// processed: the request asks about a failure, or there is no request at all
+// (npm test 2>&1 | ask).
+func wantsDiagnosis(query string, data []byte) bool {
+	return strings.TrimSpace(query) == "" || diagnoseQueryRe.MatchString(query)
+}
+
+// confirmationInput returns where to read the user's confirmation from:
+// stdin when it's a terminal, otherwise the controlling terminal, so piped
+// input never answers the prompt. It returns nil when there is no terminal.
+func confirmationInput() *os.File {
+	if isTerminal(os.Stdin) {
+		return os.Stdin
+	}
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return nil
+	}
+	if !isTerminal(tty) {
+		tty.Close()
+		return nil
+	}
+	return tty
+}
+
+// diagnose asks the model what went wrong in the piped error output.
+func diagnose(model, query string, data []byte) (string, error) {
+	prompt := buildDiagnosePrompt(query, data)
+	result, err := generate(model, prompt)
+	if err != nil {
+		return "", err
+	}
+	return stripMarkdown(result), nil
+}
+
+func buildDiagnosePrompt(query string, data []byte) string {
+	if strings.TrimSpace(query) == "" {
+		query = "What went wrong?"
+	}
+	d := newPromptData(query)
+	d.Input = sampleInput(data)
+	return renderTemplateOrDefault(diagnoseTemplateName, d)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadPipedInput(t *testing.T) {
+	data, truncated, err := readPipedInput(strings.NewReader("hello"), 10)
+	if err != nil || string(data) != "hello" || truncated {
+		t.Errorf("readPipedInput(short) = %q, %v, %v", data, truncated, err)
+	}
+
+	data, truncated, err = readPipedInput(strings.NewReader("0123456789abc"), 10)
+	if err != nil || string(data) != "0123456789" || !truncated {
+		t.Errorf("readPipedInput(long) = %q, %v, %v", data, truncated, err)
+	}
+}
+
+func TestSampleInput(t *testing.T) {
+	if got := sampleInput([]byte("a\nb\n")); got != "a\nb" {
+		t.Errorf("sampleInput(short) = %q, want %q", got, "a\nb")
+	}
+
+	long := strings.Repeat("h", pipedSampleHead) + strings.Repeat("m", 500) + strings.Repeat("t", pipedSampleTail)
+	got := sampleInput([]byte(long))
+	if strings.Contains(got, "mmm") {
+		t.Error("sampleInput() should elide the middle of long input")
+	}
+	if !strings.Contains(got, "[500 bytes omitted]") {
+		t.Errorf("sampleInput() should report omitted bytes, got ...%q", got[pipedSampleHead:pipedSampleHead+40])
+	}
+	if !strings.HasPrefix(got, strings.Repeat("h", pipedSampleHead)) || !strings.HasSuffix(got, strings.Repeat("t", pipedSampleTail)) {
+		t.Error("sampleInput() should keep the head and tail")
+	}
+}
+
+func TestWantsDiagnosis(t *testing.T) {
+	accessLog := "10.0.0.1 - - [10/Oct/2026] \"GET / HTTP/1.1\" 200\n10.0.0.2 - - [10/Oct/2026] \"GET /missing HTTP/1.1\" 404\n"
+	appLog := "INFO starting\nERROR db timeout\nINFO retrying\nINFO ready\n"
+	goError := "# example.com/app\n./main.go:12:2: undefined: foo\nFAIL\texample.com/app [build failed]\n"
+
+	tests := []struct {
+		query string
+		data  string
+		want  bool
+	}{
+		{"why did this fail", "anything", true},
+		{"what went wrong", "ok", true},
+		{"count requests per IP", accessLog, false},
+		{"count the error lines", appLog, false},
+		{"fix this", "a:\n   b: 1\n  c: 2\n", true},
+		{"explain the columns", "1 2\n3 4\n", false},
+		{"  Why is this empty?", "", true},
+		{"diagnose", "ok", true},
+		{"sum the second column", "1 2\n3 4\n", false},
+		{"", goError, true},
+		{"  ", "Traceback (most recent call last):\n  File \"x.py\"\nValueError: bad\n", true},
+		{"summarize", goError, false},
+		{"count requests per endpoint", "ERROR GET /a\nERROR GET /b\nfatal: GET /a\n", false},
+		{"parse this", "Traceback (most recent call last):\n  File \"x.py\"\nValueError: bad\n", false},
+	}
+	for _, tt := range tests {
+		if got := wantsDiagnosis(tt.query, []byte(tt.data)); got != tt.want {
+			t.Errorf("wantsDiagnosis(%q, ...) = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestBuildPromptWithPipedInput(t *testing.T) {
+	defer func() { pipedInput = nil }()
+	resetHistory()
+
+	pipedInput = []byte("10.0.0.1 GET /\n10.0.0.2 GET /about\n")
+	prompt := buildPrompt("count requests per IP")
+	if !strings.Contains(prompt, "10.0.0.2 GET /about") {
+		t.Error("buildPrompt() should include a sample of piped input")
+	}
+	if !strings.Contains(prompt, "read from stdin") {
+		t.Error("buildPrompt() should tell the model the data arrives on stdin")
+	}
+
+	pipedInput = nil
+	if strings.Contains(buildPrompt("count requests per IP"), "piped") {
+		t.Error("buildPrompt() without piped input should not mention it")
+	}
+}
+
+func TestBuildDiagnosePrompt(t *testing.T) {
+	prompt := buildDiagnosePrompt("why did this fail", []byte("npm ERR! missing script: start"))
+	for _, want := range []string{"troubleshooting", "npm ERR! missing script: start", "Question: why did this fail"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("buildDiagnosePrompt() missing %q", want)
+		}
+	}
+	if prompt := buildDiagnosePrompt("", []byte("FAIL")); !strings.Contains(prompt, "Question: What went wrong?") {
+		t.Error("buildDiagnosePrompt() without a query should ask what went wrong")
+	}
+}
+
+func TestExecuteCommandReceivesPipedInput(t *testing.T) {
+	defer func() { pipedInput = nil }()
+
+	pipedInput = []byte("b\na\nc\n")
+	stdout, _, err := executeCommand("sort")
+	if err != nil || stdout != "a\nb\nc\n" {
+		t.Errorf("executeCommand(sort) = %q, %v; want sorted piped input", stdout, err)
+	}
+}
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// PromptData is the data model available to prompt templates.
+//
+//	{{.Cwd}}          current working directory
+//	{{.OS}}           operating system (runtime.GOOS)
+//	{{.Shell}}        the user's shell ($SHELL, or /bin/sh)
+//	{{.Dialect}}      the shell syntax commands must use (sh, bash, zsh, fish)
+//	{{.DialectRules}} syntax rules for Dialect, one per line (a []string)
+//	{{.History}}      recent commands and their output, pre-formatted
+//	{{.Tools}}        curated tools found on PATH (and missing ones), empty if unknown
+//	{{.Aliases}}      the user's shell aliases and functions, empty unless --user-shell
+//	{{.ProjectInfo}}  detected project type(s), empty if none
+//	{{.Conventions}}  team conventions from .ask.yaml, empty if none
+//	{{.Request}}      the user's natural-language request
+//	{{.Input}}        sample of data piped into ask, empty if none
+//	{{.Command}}      the command to explain, or the failed command (why)
+//	{{.Tokens}}       Command's tokens, numbered, one per line (explain template only)
+//	{{.Docs}}         local man/--help descriptions of Command's flags (explain template only)
+//	{{.ExitCode}}     the failed command's exit status (why template only)
+//	{{.KubeContext}}  the current kubectl context, empty if none (assess template only)
+//	{{.GitBranch}}    the checked-out git branch, empty if none (assess template only)
+type PromptData struct {
+	Cwd          string
+	OS           string
+	Shell        string
+	Dialect      string
+	DialectRules []string
+	Tools        string
+	Aliases      string
+	History      string
+	ProjectInfo  string
+	Conventions  string
+	Request      string
+	Input        string
+	Command      string
+	Tokens       string
+	Docs         string
+	ExitCode     int
+	KubeContext  string
+	GitBranch    string
+}
+
+const (
+	commandTemplateName      = "command.tmpl"
+	explainTemplateName      = "explain.tmpl"
+	explainBlockTemplateName = "explain-block.tmpl"
+	explainFreeTemplateName  = "explain-freeform.tmpl"
+	diagnoseTemplateName     = "diagnose.tmpl"
+	whyTemplateName          = "why.tmpl"
+	planTemplateName         = "plan.tmpl"
+	scriptTemplateName       = "script.tmpl"
+	assessTemplateName       = "assess.tmpl"
+)
+
+const defaultCommandTemplate = `You are a shell command translator. Convert the user's request into a shell command.
+Current directory: {{.Cwd}}
+Operating system: {{.OS}}
+Shell: {{.Shell}}
+Shell dialect: {{.Dialect}}
+{{if .Tools}}{{.Tools}}
+{{end}}{{if .Aliases}}{{.Aliases}}
+{{end}}{{if .ProjectInfo}}{{.ProjectInfo}}
+{{end}}{{if .Conventions}}{{.Conventions}}
+{{end}}
+Recent command history:
+{{.History}}
+
+Rules:
+- Output ONLY the command, nothing else
+{{- range .DialectRules}}
+- {{.}}
+{{- end}}
+- No explanations, no markdown, no backticks
+- If unclear, make a reasonable assumption
+- Prefer simple, common commands
+{{- if .Tools}}
+- Only use tools that are installed; never suggest ones listed as not installed
+- Match flag syntax to the installed tool versions (e.g., GNU vs BSD sed)
+{{- end}}
+- Use the command history for context (e.g., "do that again", "delete the file I just created")
+- When applicable, prefer project-specific tools (e.g., "go test" for Go, "npm test" for Node.js)
+{{- if .Input}}
+- The user piped data into this request. The command receives the full data on its standard input, so read from stdin instead of a file
+
+Sample of the piped data:
+---
+{{.Input}}
+---
+{{- end}}
+
+User request: {{.Request}}`
+
+const defaultExplainTemplate = `You are a shell command explainer. The command has already been split into numbered tokens; explain what the command does and what each token means.
+
+Operating system: {{.OS}}
+Shell: {{.Shell}}
+
+Output format rules:
+- Plain text only. No markdown, no bold, no backticks, no bullet points, no headings.
+- First line: "Summary: " followed by one sentence summarizing what the command does.
+- Then one line per token, in order: the token number, a colon, and a short explanation of that token in this command (for a flag, what it does here; for an argument, what it is).
+- Nothing else.
+
+Example input: grep -rn "TODO" src/
+Tokens:
+1. grep (command)
+2. -rn (flag)
+3. "TODO" (argument)
+4. src/ (argument)
+Example output:
+Summary: Searches for the text "TODO" in all files under src/ recursively, showing line numbers.
+1: search files for lines matching a pattern
+2: -r searches directories recursively, -n shows line numbers
+3: the pattern to search for
+4: the directory to search in
+
+{{if .Docs}}Documentation of the flags from this system's man pages and --help output. Use it over what you remember; tools differ between GNU, BSD and BusyBox:
+{{.Docs}}
+
+{{end}}Command to explain: {{.Command}}
+Tokens:
+{{.Tokens}}`
+
+// defaultExplainFreeTemplate explains a command ask can't split into tokens,
+// such as fish syntax, as a whole.
+const defaultExplainFreeTemplate = `You are a shell command explainer. Given a command, output a short plain-text explanation.
+
+Operating system: {{.OS}}
+Shell: {{.Shell}}
+
+Output format rules:
+- Plain text only. No markdown, no bold, no backticks, no bullet points, no numbered lists, no headings.
+- First line: one sentence summarizing what the command does.
+- Following lines: one line per flag/argument, indented with two spaces.
+- Nothing else.
+
+Example input: grep -rn "TODO" src/
+Example output:
+Searches for the text "TODO" in all files under src/ recursively, showing line numbers.
+  -r: search recursively through directories
+  -n: show line numbers in output
+  "TODO": the pattern to search for
+  src/: the directory to search in
+
+Command to explain: {{.Command}}`
+
+const defaultExplainBlockTemplate = `You are a shell script explainer. Given a block of lines from a script, output a short plain-text explanation.
+
+Operating system: {{.OS}}
+Shell: {{.Shell}}
+
+Output format rules:
+- Plain text only. No markdown, no bold, no backticks, no bullet points, no numbered lists, no headings.
+- First line: one sentence summarizing what the block does.
+- Following lines: one line per command or step in the block, indented with two spaces.
+- Nothing else.
+
+Example input:
+mkdir -p "$BACKUP_DIR"
+pg_dump mydb | gzip > "$BACKUP_DIR/db.sql.gz"
+Example output:
+Dumps the mydb database into a compressed file in the backup directory.
+  mkdir -p "$BACKUP_DIR": create the backup directory if it does not exist
+  pg_dump mydb: write the database as SQL to stdout
+  gzip > "$BACKUP_DIR/db.sql.gz": compress the dump into the backup file
+
+Block to explain ({{.Request}}):
+{{.Command}}`
+
+const defaultDiagnoseTemplate = `You are a shell troubleshooting assistant. The user piped the output of a command into you and asked about it.
+
+Current directory: {{.Cwd}}
+Operating system: {{.OS}}
+Shell: {{.Shell}}
+{{if .ProjectInfo}}{{.ProjectInfo}}
+{{end}}
+Output format rules:
+- Plain text only. No markdown, no bold, no backticks, no bullet points, no numbered lists, no headings.
+- First line: one sentence stating the most likely cause.
+- Following lines: up to three short lines on how to fix it, including a corrected command if there is one.
+- Nothing else.
+
+Output:
+---
+{{.Input}}
+---
+
+Question: {{.Request}}`
+
+const defaultWhyTemplate = `You are a shell troubleshooting assistant. A command the user ran just failed; explain why and how to fix it.
+
+Current directory: {{.Cwd}}
+Operating system: {{.OS}}
+Shell: {{.Shell}}
+{{if .ProjectInfo}}{{.ProjectInfo}}
+{{end}}
+Output format rules:
+- Plain text only. No markdown, no bold, no backticks, no bullet points, no numbered lists, no headings.
+- First line: one sentence stating the most likely cause.
+- Following lines: up to three short lines of explanation.
+- Last line: "Fix: " followed by a single corrected command, or "Fix: none" if no command would fix it.
+- Nothing else.
+
+Failed command: {{.Command}}
+Exit status: {{.ExitCode}}
+{{if .Input}}Output:
+---
+{{.Input}}
+---
+{{else}}Output: not captured
+{{end}}`
+
+const defaultPlanTemplate = `You are a shell command planner. Break the user's request into an ordered list of shell commands.
+Current directory: {{.Cwd}}
+Operating system: {{.OS}}
+Shell: {{.Shell}}
+Shell dialect: {{.Dialect}}
+{{if .Tools}}{{.Tools}}
+{{end}}{{if .Aliases}}{{.Aliases}}
+{{end}}{{if .ProjectInfo}}{{.ProjectInfo}}
+{{end}}{{if .Conventions}}{{.Conventions}}
+{{end}}
+Recent command history:
+{{.History}}
+
+Rules:
+- Output ONLY a numbered list, one step per line: "1. command", "2. command", ...
+- Each step is a single command; do not chain unrelated actions with && or ;
+- No explanations, no markdown, no backticks
+- Use at most 10 steps, in the order they must run
+- Steps run one at a time and later steps start in the directory left by "cd" steps
+{{- range .DialectRules}}
+- {{.}}
+{{- end}}
+- Prefer simple, common commands
+{{- if .Tools}}
+- Only use tools that are installed; never suggest ones listed as not installed
+{{- end}}
+- When applicable, prefer project-specific tools (e.g., "go test" for Go, "npm test" for Node.js)
+
+User request: {{.Request}}`
+
+const defaultScriptTemplate = `You are a shell script writer. Write a reusable bash script for the user's request.
+Operating system: {{.OS}}
+{{if .Tools}}{{.Tools}}
+{{end}}{{if .ProjectInfo}}{{.ProjectInfo}}
+{{end}}{{if .Conventions}}{{.Conventions}}
+{{end}}
+Rules:
+- Output ONLY the script, nothing else; no markdown, no backticks
+- Start with "#!/usr/bin/env bash" followed by "set -euo pipefail"
+- Add a short comment at the top describing what the script does and how to run it
+- Comment each logical section
+- Put settings the user may want to change in variables at the top
+- Quote variables and paths
+{{- if .Tools}}
+- Only use tools that are installed; never use ones listed as not installed
+{{- end}}
+
+User request: {{.Request}}`
+
+const defaultAssessTemplate = `You are a cautious reviewer of shell commands. Rate the risk of running the command below and how reversible its effects are.
+
+Current directory: {{.Cwd}}
+Operating system: {{.OS}}
+Shell: {{.Shell}}
+{{if .KubeContext}}kubectl context: {{.KubeContext}}
+{{end}}{{if .GitBranch}}Git branch: {{.GitBranch}}
+{{end}}{{if .ProjectInfo}}{{.ProjectInfo}}
+{{end}}
+Consider what the command does in this environment: production clusters, contexts and branches, shared or system paths, data loss, downtime and cost.
+
+Output format rules:
+- Plain text only. No markdown.
+- Exactly three lines, nothing else:
+Risk: low, medium or high
+Reversible: yes, partly or no
+Reason: one short sentence on what could go wrong
+
+{{if .Request}}User request: {{.Request}}
+{{end}}Command: {{.Command}}`
+
+// builtinTemplates maps template file names to their compiled-in defaults.
+var builtinTemplates = map[string]string{
+	commandTemplateName:      defaultCommandTemplate,
+	explainTemplateName:      defaultExplainTemplate,
+	explainBlockTemplateName: defaultExplainBlockTemplate,
+	explainFreeTemplateName:  defaultExplainFreeTemplate,
+	diagnoseTemplateName:     defaultDiagnoseTemplate,
+	whyTemplateName:          defaultWhyTemplate,
+	planTemplateName:         defaultPlanTemplate,
+	scriptTemplateName:       defaultScriptTemplate,
+	assessTemplateName:       defaultAssessTemplate,
+}
+
+func globalTemplateDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".ask", "templates")
+}
+
+// findUp walks up from dir looking for any of names and returns the first
+// existing path, or an empty string if none is found before the filesystem root.
+// Within a single directory, names are tried in order.
+func findUp(dir string, names ...string) string {
+	dir = filepath.Clean(dir)
+	for {
+		for _, name := range names {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// templatePath returns the override file for the named template, checking the
+// project's .ask/templates directory (walking up from dir) before the global
+// ~/.ask/templates. It returns an empty string when no override exists.
+func templatePath(dir, name string) string {
+	if path := findUp(dir, filepath.Join(".ask", "templates", name)); path != "" {
+		return path
+	}
+	path := filepath.Join(globalTemplateDir(), name)
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+	return ""
+}
+
+// renderTemplate renders the named template with data, using a user override
+// when one exists and the built-in default otherwise.
+func renderTemplate(name string, data PromptData) (string, error) {
+	text := builtinTemplates[name]
+	source := "built-in"
+	if path := templatePath(data.Cwd, name); path != "" {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("reading template: %w", err)
+		}
+		text = string(raw)
+		source = path
+	}
+
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing template %s: %w", source, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("rendering template %s: %w", source, err)
+	}
+	return b.String(), nil
+}
+
+// renderTemplateOrDefault renders the named template, falling back to the
+// built-in default (with a warning on stderr) if a user override is broken.
+func renderTemplateOrDefault(name string, data PromptData) string {
+	out, err := renderTemplate(name, data)
+	if err == nil {
+		return out
+	}
+	fmt.Fprintf(os.Stderr, "warning: %v (using built-in template)\n", err)
+	tmpl := template.Must(template.New(name).Parse(builtinTemplates[name]))
+	var b strings.Builder
+	tmpl.Execute(&b, data)
+	return b.String()
+}
+
+// runPromptCommand implements "ask prompt show QUERY", printing the exact
+// prompt that would be sent to the model.
+func runPromptCommand(args []string) error {
+	if len(args) < 2 || args[0] != "show" {
+		return fmt.Errorf("usage: ask prompt show \"query\"")
+	}
+	data := newPromptData(strings.Join(args[1:], " "))
+	if !isTerminal(os.Stdin) {
+		if input, _, err := readPipedInput(os.Stdin, maxPipedBytes); err == nil && len(input) > 0 {
+			data.Input = sampleInput(input)
+		}
+	}
+	prompt, err := renderTemplate(commandTemplateName, data)
+	if err != nil {
+		return err
+	}
+	if path := templatePath(data.Cwd, commandTemplateName); path != "" {
+		fmt.Fprintf(os.Stderr, "template: %s\n", path)
+	} else {
+		fmt.Fprintln(os.Stderr, "template: built-in")
+	}
+	fmt.Println(prompt)
+	return nil
+}
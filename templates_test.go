@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTemplate(t *testing.T, dir, name, text string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRenderTemplateBuiltin(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	data := PromptData{Cwd: t.TempDir(), OS: "linux", Shell: "/bin/zsh", History: "No previous commands.", Request: "list files"}
+
+	got, err := renderTemplate(commandTemplateName, data)
+	if err != nil {
+		t.Fatalf("renderTemplate() error: %v", err)
+	}
+	for _, want := range []string{"Current directory: " + data.Cwd, "Operating system: linux", "Shell: /bin/zsh", "User request: list files"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("renderTemplate() missing %q", want)
+		}
+	}
+	if strings.Contains(got, "Detected project type") {
+		t.Error("renderTemplate() should omit project line when ProjectInfo is empty")
+	}
+}
+
+func TestRenderTemplateGlobalOverride(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeTemplate(t, filepath.Join(home, ".ask", "templates"), commandTemplateName, "global: {{.Request}}")
+
+	got, err := renderTemplate(commandTemplateName, PromptData{Cwd: t.TempDir(), Request: "hi"})
+	if err != nil {
+		t.Fatalf("renderTemplate() error: %v", err)
+	}
+	if got != "global: hi" {
+		t.Errorf("renderTemplate() = %q, want %q", got, "global: hi")
+	}
+}
+
+func TestRenderTemplateProjectOverridesGlobal(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeTemplate(t, filepath.Join(home, ".ask", "templates"), explainTemplateName, "global")
+
+	project := t.TempDir()
+	writeTemplate(t, filepath.Join(project, ".ask", "templates"), explainTemplateName, "project: {{.Command}}")
+	sub := filepath.Join(project, "src", "pkg")
+	os.MkdirAll(sub, 0755)
+
+	got, err := renderTemplate(explainTemplateName, PromptData{Cwd: sub, Command: "ls"})
+	if err != nil {
+		t.Fatalf("renderTemplate() error: %v", err)
+	}
+	if got != "project: ls" {
+		t.Errorf("renderTemplate() = %q, want %q", got, "project: ls")
+	}
+}
+
+func TestRenderTemplateBrokenOverride(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeTemplate(t, filepath.Join(home, ".ask", "templates"), commandTemplateName, "{{.Nope}}")
+
+	data := PromptData{Cwd: t.TempDir(), Request: "list files"}
+	if _, err := renderTemplate(commandTemplateName, data); err == nil {
+		t.Error("renderTemplate() with unknown field should fail")
+	}
+	got := renderTemplateOrDefault(commandTemplateName, data)
+	if !strings.Contains(got, "shell command translator") {
+		t.Errorf("renderTemplateOrDefault() should fall back to built-in, got %q", got)
+	}
+}
+
+func TestFindUp(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "marker"), []byte(""), 0644)
+	deep := filepath.Join(root, "a", "b", "c")
+	os.MkdirAll(deep, 0755)
+
+	if got := findUp(deep, "marker"); got != filepath.Join(root, "marker") {
+		t.Errorf("findUp() = %q, want %q", got, filepath.Join(root, "marker"))
+	}
+	if got := findUp(deep, "does-not-exist-anywhere"); got != "" {
+		t.Errorf("findUp(missing) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+
+	"github.com/chzyer/readline"
+)
+
+const (
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorCyan   = "\033[36m"
+	colorReset  = "\033[0m"
+)
+
+// assumeYes runs generated commands without asking (--yes). Without it,
+// commands only run after confirmation on a terminal.
+var assumeYes bool
+
+func isTerminal(f *os.File) bool {
+	return readline.IsTerminal(int(f.Fd()))
+}
+
+// colorEnabled reports whether ANSI colors should be written to f: only on
+// a terminal, and never when NO_COLOR is set (https://no-color.org).
+func colorEnabled(f *os.File) bool {
+	return os.Getenv("NO_COLOR") == "" && isTerminal(f)
+}
+
+// paint wraps s in color when colors are enabled for f.
+func paint(f *os.File, color, s string) string {
+	if !colorEnabled(f) {
+		return s
+	}
+	return color + s + colorReset
+}
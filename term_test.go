@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// withPipe swaps *target for one end of a pipe for the duration of the test:
+// the read end for os.Stdin, the write end otherwise. It returns the other end.
+func withPipe(t *testing.T, target **os.File) *os.File {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := *target
+	mine, theirs := w, r
+	if target == &os.Stdin {
+		mine, theirs = r, w
+	}
+	*target = mine
+	t.Cleanup(func() {
+		*target = orig
+		r.Close()
+		w.Close()
+	})
+	return theirs
+}
+
+func TestPaintWithoutTerminal(t *testing.T) {
+	r, w, _ := os.Pipe()
+	defer r.Close()
+	defer w.Close()
+
+	if got := paint(w, colorRed, "error"); got != "error" {
+		t.Errorf("paint() on a pipe = %q, want plain text", got)
+	}
+	if colorEnabled(w) {
+		t.Error("colorEnabled() on a pipe = true, want false")
+	}
+}
+
+func TestColorEnabledNoColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+	if colorEnabled(os.Stderr) {
+		t.Error("colorEnabled() with NO_COLOR set = true, want false")
+	}
+}
+
+func TestSpinnerDisabledWithoutTerminal(t *testing.T) {
+	withPipe(t, &os.Stderr)
+
+	s := NewSpinner("Thinking...")
+	if !s.disabled {
+		t.Fatal("spinner should be disabled when stderr is not a terminal")
+	}
+	// Start/Stop must be safe no-ops
+	s.Start()
+	s.Stop()
+}
+
+func TestConfirmRefusesPipedStdin(t *testing.T) {
+	defer func(v bool) { assumeYes = v }(assumeYes)
+	w := withPipe(t, &os.Stdin)
+	w.Write([]byte("\n"))
+	withPipe(t, &os.Stderr)
+
+	assumeYes = false
+	if confirm("rm -rf build") {
+		t.Error("confirm() should refuse when stdin is a pipe, even if it contains Enter")
+	}
+
+	assumeYes = true
+	if !confirm("rm -rf build") {
+		t.Error("confirm() with --yes should approve")
+	}
+}
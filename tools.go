@@ -0,0 +1,204 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	toolsCacheFileName = "tools.json"
+	toolsCacheTTL      = 24 * time.Hour
+)
+
+// curatedTools are the binaries worth telling the model about: modern
+// replacements it likes to suggest, platform-specific variants, and common
+// infrastructure CLIs.
+var curatedTools = []string{
+	// Core utilities whose flavor (GNU vs BSD) changes flag syntax
+	"sed", "grep", "awk", "find", "tar", "date", "xargs", "stat",
+	// GNU variants commonly installed on macOS
+	"gsed", "ggrep", "gawk", "gfind", "gdate",
+	// Modern replacements
+	"rg", "fd", "fdfind", "bat", "eza", "exa", "fzf", "jq", "yq", "tree", "htop",
+	// Network
+	"curl", "wget", "lsof", "ss", "netstat", "ip", "ifconfig", "nc",
+	// Development
+	"git", "gh", "make", "go", "node", "npm", "pnpm", "yarn", "python3", "pip3", "cargo", "shellcheck",
+	// Containers and cloud
+	"docker", "podman", "kubectl", "helm", "terraform", "aws", "gcloud",
+	// Package managers
+	"brew", "apt", "dnf", "yum", "pacman", "apk",
+}
+
+// versionedTools are probed with --version so the model can tell GNU and BSD
+// implementations apart.
+var versionedTools = map[string]bool{
+	"sed": true, "grep": true, "awk": true, "find": true, "tar": true, "date": true,
+}
+
+// InstalledTool is a curated tool found on PATH.
+type InstalledTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type toolsCache struct {
+	Path      string          `json:"path"`
+	CheckedAt time.Time       `json:"checked_at"`
+	Installed []InstalledTool `json:"installed"`
+	Missing   []string        `json:"missing"`
+}
+
+func toolsCachePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".ask", toolsCacheFileName)
+}
+
+var versionRe = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// toolFlavor reduces the output of "tool --version" to a short description
+// such as "GNU 4.9". It returns an empty string when the flavor is unknown,
+// which is the case for BSD tools since they reject --version.
+func toolFlavor(output string, err error) string {
+	line := strings.TrimSpace(strings.SplitN(output, "\n", 2)[0])
+	if err != nil || line == "" {
+		return ""
+	}
+	version := versionRe.FindString(line)
+	switch {
+	case strings.Contains(line, "GNU") && version != "":
+		return "GNU " + version
+	case strings.Contains(line, "GNU"):
+		return "GNU"
+	case strings.Contains(line, "BusyBox"):
+		return "BusyBox"
+	case strings.Contains(strings.ToLower(line), "bsd"):
+		return "BSD"
+	default:
+		return ""
+	}
+}
+
+func probeVersion(name string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, "--version").CombinedOutput()
+	flavor := toolFlavor(string(out), err)
+	if flavor == "" && (runtime.GOOS == "darwin" || strings.HasSuffix(runtime.GOOS, "bsd")) {
+		flavor = "BSD"
+	}
+	return flavor
+}
+
+func scanTools() *toolsCache {
+	cache := &toolsCache{Path: os.Getenv("PATH"), CheckedAt: time.Now()}
+	for _, name := range curatedTools {
+		if _, err := exec.LookPath(name); err != nil {
+			cache.Missing = append(cache.Missing, name)
+			continue
+		}
+		tool := InstalledTool{Name: name}
+		if versionedTools[name] {
+			tool.Version = probeVersion(name)
+		}
+		cache.Installed = append(cache.Installed, tool)
+	}
+	return cache
+}
+
+// loadInstalledTools returns the cached tool scan, rescanning when the cache
+// is older than toolsCacheTTL or was taken with a different PATH.
+func loadInstalledTools() *toolsCache {
+	path := toolsCachePath()
+	if data, err := os.ReadFile(path); err == nil {
+		var cache toolsCache
+		if json.Unmarshal(data, &cache) == nil &&
+			cache.Path == os.Getenv("PATH") &&
+			time.Since(cache.CheckedAt) < toolsCacheTTL {
+			return &cache
+		}
+	}
+
+	cache := scanTools()
+	if data, err := json.MarshalIndent(cache, "", "  "); err == nil {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, data, 0644)
+	}
+	return cache
+}
+
+// format describes the tool scan for the prompt.
+func (c *toolsCache) format() string {
+	if c == nil || len(c.Installed) == 0 {
+		return ""
+	}
+	var names []string
+	for _, t := range c.Installed {
+		if t.Version != "" {
+			names = append(names, fmt.Sprintf("%s (%s)", t.Name, t.Version))
+		} else {
+			names = append(names, t.Name)
+		}
+	}
+	s := "Installed tools: " + strings.Join(names, ", ")
+	if len(c.Missing) > 0 {
+		s += "\nNot installed: " + strings.Join(c.Missing, ", ")
+	}
+	return s
+}
+
+// shellBuiltins are commands that never appear on PATH.
+var shellBuiltins = map[string]bool{
+	"cd": true, "echo": true, "export": true, "source": true, ".": true,
+	"alias": true, "unalias": true, "set": true, "unset": true, "eval": true,
+	"read": true, "type": true, "exit": true, "return": true, "shift": true,
+	"test": true, "[": true, "[[": true, "printf": true, "pwd": true,
+	"history": true, "jobs": true, "fg": true, "bg": true, "wait": true,
+	"trap": true, "ulimit": true, "umask": true, "builtin": true, "local": true,
+	"declare": true, "typeset": true, "let": true, "true": true, "false": true,
+	"if": true, "for": true, "while": true, "until": true, "case": true,
+	"function": true, "{": true, "(": true, "!": true,
+}
+
+// firstBinary returns the program cmd runs first, looking through variable
+// assignments and wrappers such as sudo (see commandWrappers). It returns an
+// empty string for shell builtins and keywords, and for commands that don't
+// parse.
+func firstBinary(cmd string) string {
+	list, err := parseShell(cmd)
+	if err != nil {
+		return ""
+	}
+	for _, c := range list.commands() {
+		if len(c.Words) == 0 {
+			continue // a subshell or group; its commands follow
+		}
+		if words := unwrapLocal(c.Words); len(words) > 0 && !shellBuiltins[words[0].Value] {
+			return words[0].Value
+		}
+		return ""
+	}
+	return ""
+}
+
+// warnIfMissingBinary warns when the command's first program isn't installed.
+func warnIfMissingBinary(cmd string) {
+	bin := firstBinary(cmd)
+	if bin == "" {
+		return
+	}
+	if useUserShell && loadShellAliases(targetDialect).has(bin) {
+		return
+	}
+	if _, err := exec.LookPath(expandHome(bin)); err != nil {
+		fmt.Fprintln(os.Stderr, paint(os.Stderr, colorYellow, fmt.Sprintf("  ⚠ Warning: %s is not installed (not found on PATH)", bin)))
+	}
+}
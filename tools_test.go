@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestToolFlavor(t *testing.T) {
+	tests := []struct {
+		output string
+		err    error
+		want   string
+	}{
+		{"sed (GNU sed) 4.9\nCopyright...", nil, "GNU 4.9"},
+		{"find (GNU findutils) 4.9.0\n", nil, "GNU 4.9.0"},
+		{"GNU Awk 5.1.0, API: 3.0\n", nil, "GNU 5.1.0"},
+		{"GNU bash", nil, "GNU"},
+		{"BusyBox v1.36.1 (2023-06-02) multi-call binary.", nil, "BusyBox"},
+		{"bsdtar 3.5.3 - libarchive 3.5.3", nil, "BSD"},
+		{"sed: illegal option -- -\nusage: sed script", errors.New("exit status 1"), ""},
+		{"", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := toolFlavor(tt.output, tt.err); got != tt.want {
+			t.Errorf("toolFlavor(%q) = %q, want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestToolsCacheFormat(t *testing.T) {
+	var empty *toolsCache
+	if got := empty.format(); got != "" {
+		t.Errorf("nil format() = %q, want empty", got)
+	}
+
+	c := &toolsCache{
+		Installed: []InstalledTool{{Name: "sed", Version: "GNU 4.9"}, {Name: "jq"}},
+		Missing:   []string{"rg", "fd"},
+	}
+	want := "Installed tools: sed (GNU 4.9), jq\nNot installed: rg, fd"
+	if got := c.format(); got != want {
+		t.Errorf("format() = %q, want %q", got, want)
+	}
+}
+
+func TestLoadInstalledToolsUsesCache(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	cached := toolsCache{
+		Path:      os.Getenv("PATH"),
+		CheckedAt: time.Now(),
+		Installed: []InstalledTool{{Name: "definitely-cached-tool"}},
+	}
+	data, _ := json.Marshal(cached)
+	os.MkdirAll(filepath.Join(home, ".ask"), 0755)
+	os.WriteFile(toolsCachePath(), data, 0644)
+
+	got := loadInstalledTools()
+	if len(got.Installed) != 1 || got.Installed[0].Name != "definitely-cached-tool" {
+		t.Errorf("loadInstalledTools() ignored fresh cache: %+v", got.Installed)
+	}
+
+	// A different PATH invalidates the cache
+	t.Setenv("PATH", t.TempDir())
+	got = loadInstalledTools()
+	if len(got.Installed) != 0 {
+		t.Errorf("loadInstalledTools() with empty PATH = %+v, want none installed", got.Installed)
+	}
+	if len(got.Missing) != len(curatedTools) {
+		t.Errorf("Missing = %d tools, want %d", len(got.Missing), len(curatedTools))
+	}
+}
+
+func TestFirstBinary(t *testing.T) {
+	tests := []struct {
+		cmd  string
+		want string
+	}{
+		{"rg TODO src/", "rg"},
+		{"sudo apt install jq", "apt"},
+		{"sudo -u postgres psql", "psql"},
+		{"FOO=bar BAZ=1 make build", "make"},
+		{"env GOOS=linux go build", "go"},
+		{"time ./run.sh", "./run.sh"},
+		{"cd /tmp && ls", ""},
+		{"for f in *.go; do echo $f; done", ""},
+		{"(fd -e go)", "fd"},
+		{"nice -n 10 timeout 5 xz -9 big.tar", "xz"},
+		{"sudo -iu postgres psql", "psql"},
+		{"docker exec -it web psql", "docker"},
+		{"watch -n 1 df -h", "df"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := firstBinary(tt.cmd); got != tt.want {
+			t.Errorf("firstBinary(%q) = %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestBuildPromptIncludesTools(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	resetHistory()
+
+	prompt := buildPrompt("search for TODO")
+	if !strings.Contains(prompt, "Installed tools:") {
+		t.Skip("no curated tools on PATH in this environment")
+	}
+	if !strings.Contains(prompt, "Only use tools that are installed") {
+		t.Error("buildPrompt() should tell the model to stick to installed tools")
+	}
+}
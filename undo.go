@@ -0,0 +1,352 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// defaultTrashLimitMB caps the size of ~/.ask/trash unless ASK_TRASH_MB
+	// says otherwise.
+	defaultTrashLimitMB = 1024
+	// maxSnapshots is how many snapshots the trash keeps.
+	maxSnapshots = 20
+	// snapshotTimeFormat names snapshot directories so they sort by time.
+	snapshotTimeFormat = "20060102-150405.000000000"
+	manifestName       = "manifest.json"
+)
+
+// snapshotFiles (--undo, ASK_UNDO) snapshots the files a flagged command
+// would delete or overwrite before running it, so "ask undo" can restore
+// them.
+var snapshotFiles bool
+
+// trashManifest records what a snapshot holds.
+type trashManifest struct {
+	Time    time.Time    `json:"time"`
+	Command string       `json:"command"`
+	Entries []trashEntry `json:"entries"`
+}
+
+// trashEntry is one path saved in a snapshot.
+type trashEntry struct {
+	Path    string      `json:"path"`             // the original absolute path
+	Stored  string      `json:"stored,omitempty"` // its contents, relative to the snapshot
+	Mode    fs.FileMode `json:"mode"`
+	UID     int         `json:"uid"`
+	GID     int         `json:"gid"`
+	Dir     bool        `json:"dir,omitempty"`
+	Symlink string      `json:"symlink,omitempty"` // the link target
+}
+
+func trashDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".ask", "trash")
+}
+
+// trashLimit returns the maximum size of the trash in bytes.
+func trashLimit() int64 {
+	mb := defaultTrashLimitMB
+	if n, err := strconv.Atoi(os.Getenv("ASK_TRASH_MB")); err == nil && n > 0 {
+		mb = n
+	}
+	return int64(mb) << 20
+}
+
+// snapshotProblem returns why entries can't be snapshotted, or "".
+func snapshotProblem(entries []*blastEntry) string {
+	var size int64
+	for _, e := range entries {
+		if e.partial {
+			return "too many files to snapshot"
+		}
+		if !e.metadata {
+			size += e.bytes
+		}
+	}
+	if size > trashLimit() {
+		return fmt.Sprintf("%s is over the trash limit of %s (ASK_TRASH_MB)", humanSize(size), humanSize(trashLimit()))
+	}
+	return ""
+}
+
+// printSnapshotNotice tells the user whether the files shown will be
+// snapshotted before the command runs.
+func printSnapshotNotice(entries []*blastEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	if problem := snapshotProblem(entries); problem != "" {
+		fmt.Fprintln(os.Stderr, paint(os.Stderr, colorYellow, "  ⚠ No undo snapshot: "+problem))
+		return
+	}
+	note := "  ↺ These files are snapshotted before running (ask undo restores them)"
+	for _, e := range entries {
+		if len(e.unresolved) > 0 {
+			note += "; paths only known at run time are not"
+			break
+		}
+	}
+	fmt.Fprintln(os.Stderr, paint(os.Stderr, colorCyan, note))
+}
+
+// snapshotBeforeRun snapshots the files the command will affect, if
+// snapshots are on. A failed snapshot is reported but doesn't stop cmd.
+func snapshotBeforeRun(cmd string, entries []*blastEntry) {
+	if !snapshotFiles || len(entries) == 0 || snapshotProblem(entries) != "" {
+		return
+	}
+	if _, err := takeSnapshot(trashDir(), cmd, entries, time.Now()); err != nil {
+		fmt.Fprintln(os.Stderr, paint(os.Stderr, colorYellow, fmt.Sprintf("  ⚠ Undo snapshot failed: %v", err)))
+	}
+}
+
+// takeSnapshot saves the paths of entries into a new snapshot under trash.
+// Files that will be deleted or moved are hardlinked where possible; files
+// that will be overwritten in place are copied. For permission changes only
+// the modes and owners are recorded.
+func takeSnapshot(trash, cmd string, entries []*blastEntry, now time.Time) (string, error) {
+	dir := filepath.Join(trash, now.Format(snapshotTimeFormat))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	manifest := trashManifest{Time: now, Command: cmd}
+	seen := make(map[string]bool)
+	for _, e := range entries {
+		for _, path := range e.paths {
+			if seen[path] {
+				continue
+			}
+			seen[path] = true
+			info, err := os.Lstat(path)
+			if err != nil {
+				continue
+			}
+			entry := trashEntry{Path: path, Mode: info.Mode()}
+			if st, ok := info.Sys().(*syscall.Stat_t); ok {
+				entry.UID, entry.GID = int(st.Uid), int(st.Gid)
+			}
+			switch {
+			case e.metadata:
+			case info.IsDir():
+				entry.Dir = true
+			case info.Mode()&fs.ModeSymlink != 0:
+				entry.Symlink, _ = os.Readlink(path)
+			case info.Mode().IsRegular():
+				entry.Stored = filepath.Join("files", path)
+				if err := saveFile(path, filepath.Join(dir, entry.Stored), info.Mode(), !e.overwrites); err != nil {
+					os.RemoveAll(dir)
+					return "", err
+				}
+			default:
+				continue // devices, sockets and pipes
+			}
+			manifest.Entries = append(manifest.Entries, entry)
+		}
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, manifestName), data, 0600)
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	pruneTrash(trash, dir)
+	return dir, nil
+}
+
+// saveFile hardlinks src to dst if link is set and that works, or copies it.
+func saveFile(src, dst string, mode fs.FileMode, link bool) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return err
+	}
+	if link && os.Link(src, dst) == nil {
+		return nil
+	}
+	return copyFile(src, dst, mode)
+}
+
+func copyFile(src, dst string, mode fs.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// listSnapshots returns the snapshot directories in trash, oldest first.
+func listSnapshots(trash string) []string {
+	dirEntries, err := os.ReadDir(trash)
+	if err != nil {
+		return nil
+	}
+	var dirs []string
+	for _, d := range dirEntries {
+		if d.IsDir() {
+			dirs = append(dirs, filepath.Join(trash, d.Name()))
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
+
+// pruneTrash removes the oldest snapshots until the trash is within
+// maxSnapshots and the size limit. The snapshot just taken is kept.
+func pruneTrash(trash, keep string) {
+	dirs := listSnapshots(trash)
+	sizes := make(map[string]int64)
+	var total int64
+	for _, dir := range dirs {
+		sizes[dir] = dirSize(dir)
+		total += sizes[dir]
+	}
+	for _, dir := range dirs {
+		if dir == keep || len(dirs) <= maxSnapshots && total <= trashLimit() {
+			break
+		}
+		os.RemoveAll(dir)
+		total -= sizes[dir]
+		dirs = dirs[1:]
+	}
+}
+
+func loadManifest(dir string) (*trashManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, manifestName))
+	if err != nil {
+		return nil, err
+	}
+	var m trashManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", filepath.Join(dir, manifestName), err)
+	}
+	return &m, nil
+}
+
+// restoreSnapshot puts the paths saved in the snapshot dir back: directories
+// first, then files and symlinks, then modes and owners.
+func restoreSnapshot(dir string, m *trashManifest) error {
+	entries := append([]trashEntry(nil), m.Entries...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	var errs []string
+	for _, e := range entries {
+		var err error
+		switch {
+		case e.Dir:
+			err = os.MkdirAll(e.Path, e.Mode.Perm())
+		case e.Symlink != "":
+			os.Remove(e.Path)
+			if err = os.MkdirAll(filepath.Dir(e.Path), 0755); err == nil {
+				err = os.Symlink(e.Symlink, e.Path)
+			}
+		case e.Stored != "":
+			if info, statErr := os.Lstat(e.Path); statErr == nil && info.IsDir() {
+				err = fmt.Errorf("a directory is in the way")
+				break
+			}
+			os.Remove(e.Path)
+			if err = os.MkdirAll(filepath.Dir(e.Path), 0755); err == nil {
+				err = saveFile(filepath.Join(dir, e.Stored), e.Path, e.Mode, true)
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", e.Path, err))
+		}
+	}
+	for _, e := range entries {
+		if e.Symlink != "" {
+			continue
+		}
+		if err := os.Chmod(e.Path, e.Mode.Perm()|e.Mode&(fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)); err == nil {
+			os.Lchown(e.Path, e.UID, e.GID) // only works for our own files or as root
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("could not restore %d path(s):\n  %s", len(errs), strings.Join(errs, "\n  "))
+	}
+	return nil
+}
+
+// runUndo restores the most recent snapshot after confirmation, then removes
+// it, so the next undo goes one operation further back.
+func runUndo() error {
+	dirs := listSnapshots(trashDir())
+	if len(dirs) == 0 {
+		return fmt.Errorf("nothing to undo (snapshots are taken with --undo or ASK_UNDO=1)")
+	}
+	dir := dirs[len(dirs)-1]
+	m, err := loadManifest(dir)
+	if err != nil {
+		return err
+	}
+	shown := fmt.Sprintf("↺ restore %s from %s: %s", plural(len(m.Entries), "path"), m.Time.Format("2006-01-02 15:04:05"), m.Command)
+	if !confirmWith(paint(os.Stderr, colorYellow, shown), "[Enter to restore]") {
+		return nil
+	}
+	if err := restoreSnapshot(dir, m); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "restored %s\n", plural(len(m.Entries), "path"))
+	return os.RemoveAll(dir)
+}
+
+// listUndo prints the snapshots in the trash, newest first.
+func listUndo() error {
+	dirs := listSnapshots(trashDir())
+	if len(dirs) == 0 {
+		fmt.Println("No snapshots.")
+		return nil
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		m, err := loadManifest(dirs[i])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+			continue
+		}
+		fmt.Printf("%s  %-10s %s\n", m.Time.Format("2006-01-02 15:04:05"), plural(len(m.Entries), "path"), m.Command)
+	}
+	return nil
+}
+
+// runUndoCommand implements "ask undo" and "ask undo list".
+func runUndoCommand(args []string) error {
+	switch {
+	case len(args) == 0:
+		return runUndo()
+	case len(args) == 1 && args[0] == "list":
+		return listUndo()
+	}
+	return fmt.Errorf("usage: ask undo [list]")
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	dir := blastDir(t, map[string]string{
+		"build/a.o":     "aaaa",
+		"build/sub/b.o": "bb",
+		"notes.txt":     "keep me",
+		"run.sh":        "echo hi",
+	})
+	os.Chmod(filepath.Join(dir, "run.sh"), 0755)
+	trash := t.TempDir()
+
+	cmd := "rm -rf build && echo > notes.txt && chmod -R 600 run.sh"
+	entries := blastRadius(cmd)
+	if len(entries) != 3 {
+		t.Fatalf("got %d blast entries, want 3", len(entries))
+	}
+	snap, err := takeSnapshot(trash, cmd, entries, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// what the command does
+	os.RemoveAll(filepath.Join(dir, "build"))
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("\n"), 0644)
+	os.Chmod(filepath.Join(dir, "run.sh"), 0600)
+
+	m, err := loadManifest(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Command != cmd || len(m.Entries) != 6 {
+		t.Errorf("manifest = %q with %d entries, want 6", m.Command, len(m.Entries))
+	}
+	if err := restoreSnapshot(snap, m); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{"build/a.o": "aaaa", "build/sub/b.o": "bb", "notes.txt": "keep me"} {
+		if got, _ := os.ReadFile(filepath.Join(dir, name)); string(got) != want {
+			t.Errorf("%s = %q after restore, want %q", name, got, want)
+		}
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "run.sh")); info.Mode().Perm() != 0755 {
+		t.Errorf("run.sh mode = %v after restore, want 0755", info.Mode().Perm())
+	}
+}
+
+func TestSnapshotProblem(t *testing.T) {
+	t.Setenv("ASK_TRASH_MB", "1")
+	if p := snapshotProblem([]*blastEntry{{bytes: 2 << 20}}); p == "" {
+		t.Error("2 MB with a 1 MB trash limit should not be snapshotted")
+	}
+	if p := snapshotProblem([]*blastEntry{{bytes: 2 << 20, metadata: true}}); p != "" {
+		t.Errorf("permission changes store no data, got %q", p)
+	}
+	if p := snapshotProblem([]*blastEntry{{partial: true}}); p == "" {
+		t.Error("a partial count should not be snapshotted")
+	}
+}
+
+func TestPruneTrash(t *testing.T) {
+	trash := t.TempDir()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var last string
+	for i := 0; i < maxSnapshots+3; i++ {
+		last = filepath.Join(trash, start.Add(time.Duration(i)*time.Minute).Format(snapshotTimeFormat))
+		os.MkdirAll(last, 0700)
+	}
+	pruneTrash(trash, last)
+	dirs := listSnapshots(trash)
+	if len(dirs) != maxSnapshots {
+		t.Fatalf("%d snapshots after pruning, want %d", len(dirs), maxSnapshots)
+	}
+	if dirs[len(dirs)-1] != last || filepath.Base(dirs[0]) != start.Add(3*time.Minute).Format(snapshotTimeFormat) {
+		t.Errorf("pruning should drop the oldest snapshots, kept %s..%s", filepath.Base(dirs[0]), filepath.Base(dirs[len(dirs)-1]))
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	aliasesCacheFileName = "aliases.json"
+	aliasesCacheTTL      = time.Hour
+	maxPromptAliases     = 100
+)
+
+// useUserShell runs commands through an interactive instance of the user's
+// shell so aliases, functions and PATH changes from rc files are available.
+var useUserShell bool
+
+// aliasListScripts print alias names, a "---" separator, then function names.
+var aliasListScripts = map[shellDialect]string{
+	dialectBash: `compgen -a; echo ---; compgen -A function`,
+	dialectZsh:  `print -l ${(k)aliases}; echo ---; print -l ${(k)functions}`,
+	dialectFish: `echo ---; functions -n`,
+}
+
+// interactiveShellNoise are stderr lines printed by shells started with -i
+// without a controlling terminal.
+var interactiveShellNoise = []string{
+	"cannot set terminal process group",
+	"no job control in this shell",
+}
+
+type aliasesCache struct {
+	Dialect   shellDialect `json:"dialect"`
+	CheckedAt time.Time    `json:"checked_at"`
+	Aliases   []string     `json:"aliases"`
+	Functions []string     `json:"functions"`
+}
+
+func aliasesCachePath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".ask", aliasesCacheFileName)
+}
+
+// shellCommand builds the process that runs cmd in the target dialect,
+// as an interactive shell when useUserShell is set.
+func shellCommand(cmd string) *exec.Cmd {
+	if useUserShell {
+		return exec.Command(targetDialect.interpreter(), "-i", "-c", cmd)
+	}
+	return exec.Command(targetDialect.interpreter(), "-c", cmd)
+}
+
+// stripShellNoise removes job-control warnings emitted by interactive shells.
+func stripShellNoise(stderr string) string {
+	if !useUserShell || stderr == "" {
+		return stderr
+	}
+	var kept []string
+	for _, line := range strings.SplitAfter(stderr, "\n") {
+		noisy := false
+		for _, n := range interactiveShellNoise {
+			if strings.Contains(line, n) {
+				noisy = true
+				break
+			}
+		}
+		if !noisy {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "")
+}
+
+// parseAliasList splits the output of an aliasListScripts entry into alias and
+// function names, dropping private helpers: names with a leading "_" or
+// "fish_", and plugin internals like nvm_ls when nvm itself is listed.
+func parseAliasList(output string) (aliases, functions []string) {
+	inFunctions := false
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		name := strings.TrimSpace(line)
+		if name == "---" {
+			inFunctions = true
+			continue
+		}
+		if name == "" || strings.ContainsAny(name, " \t") || seen[name] ||
+			strings.HasPrefix(name, "_") || strings.HasPrefix(name, "fish_") {
+			continue
+		}
+		seen[name] = true
+		if inFunctions {
+			functions = append(functions, name)
+		} else {
+			aliases = append(aliases, name)
+		}
+	}
+	isHelper := func(name string) bool {
+		if i := strings.Index(name, "_"); i > 0 {
+			return seen[name[:i]]
+		}
+		return false
+	}
+	aliases = filterNames(aliases, isHelper)
+	functions = filterNames(functions, isHelper)
+	sort.Strings(aliases)
+	sort.Strings(functions)
+	return aliases, functions
+}
+
+func filterNames(names []string, drop func(string) bool) []string {
+	var kept []string
+	for _, name := range names {
+		if !drop(name) {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
+
+// loadShellAliases returns the alias and function names defined in the
+// user's interactive shell, cached for aliasesCacheTTL.
+func loadShellAliases(d shellDialect) *aliasesCache {
+	path := aliasesCachePath()
+	if data, err := os.ReadFile(path); err == nil {
+		var cache aliasesCache
+		if json.Unmarshal(data, &cache) == nil &&
+			cache.Dialect == d &&
+			time.Since(cache.CheckedAt) < aliasesCacheTTL {
+			return &cache
+		}
+	}
+
+	cache := &aliasesCache{Dialect: d, CheckedAt: time.Now()}
+	if script, ok := aliasListScripts[d]; ok {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		out, _ := exec.CommandContext(ctx, d.interpreter(), "-i", "-c", script).Output()
+		cache.Aliases, cache.Functions = parseAliasList(string(out))
+	}
+	if data, err := json.MarshalIndent(cache, "", "  "); err == nil {
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, data, 0644)
+	}
+	return cache
+}
+
+// has reports whether name is one of the user's aliases or functions.
+func (c *aliasesCache) has(name string) bool {
+	for _, n := range append(append([]string(nil), c.Aliases...), c.Functions...) {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// format lists alias and function names for the prompt.
+func (c *aliasesCache) format() string {
+	if c == nil {
+		return ""
+	}
+	names := append(append([]string(nil), c.Aliases...), c.Functions...)
+	if len(names) == 0 {
+		return ""
+	}
+	if len(names) > maxPromptAliases {
+		names = names[:maxPromptAliases]
+	}
+	return "User's shell aliases and functions (available, prefer them when they fit): " + strings.Join(names, ", ")
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAliasList(t *testing.T) {
+	output := "ll\ngst\n_private\nll\n---\nmkcd\nnvm\nnvm_ls\nfish_prompt\n_complete\n\n"
+	aliases, functions := parseAliasList(output)
+
+	if want := []string{"gst", "ll"}; !reflect.DeepEqual(aliases, want) {
+		t.Errorf("aliases = %v, want %v", aliases, want)
+	}
+	if want := []string{"mkcd", "nvm"}; !reflect.DeepEqual(functions, want) {
+		t.Errorf("functions = %v, want %v", functions, want)
+	}
+}
+
+func TestAliasesCacheFormat(t *testing.T) {
+	var empty *aliasesCache
+	if got := empty.format(); got != "" {
+		t.Errorf("nil format() = %q, want empty", got)
+	}
+	if got := (&aliasesCache{}).format(); got != "" {
+		t.Errorf("empty format() = %q, want empty", got)
+	}
+
+	c := &aliasesCache{Aliases: []string{"ll"}, Functions: []string{"mkcd"}}
+	if got := c.format(); !strings.HasSuffix(got, ": ll, mkcd") {
+		t.Errorf("format() = %q", got)
+	}
+	if !c.has("mkcd") || c.has("ls") {
+		t.Error("has() should only match listed names")
+	}
+
+	many := &aliasesCache{}
+	for i := 0; i < maxPromptAliases+10; i++ {
+		many.Aliases = append(many.Aliases, "a")
+	}
+	listed := strings.Split(strings.SplitN(many.format(), ": ", 2)[1], ", ")
+	if len(listed) != maxPromptAliases {
+		t.Errorf("format() listed %d names, want %d", len(listed), maxPromptAliases)
+	}
+}
+
+func TestStripShellNoise(t *testing.T) {
+	defer func(v bool) { useUserShell = v }(useUserShell)
+
+	stderr := "bash: cannot set terminal process group (-1): Inappropriate ioctl for device\nbash: no job control in this shell\nls: foo: No such file\n"
+
+	useUserShell = false
+	if got := stripShellNoise(stderr); got != stderr {
+		t.Errorf("stripShellNoise() without user shell changed output: %q", got)
+	}
+
+	useUserShell = true
+	if got := stripShellNoise(stderr); got != "ls: foo: No such file\n" {
+		t.Errorf("stripShellNoise() = %q", got)
+	}
+}
+
+func TestShellCommandArgs(t *testing.T) {
+	defer func(v bool) { useUserShell = v }(useUserShell)
+
+	useUserShell = false
+	if got := shellCommand("ls").Args; !reflect.DeepEqual(got[1:], []string{"-c", "ls"}) {
+		t.Errorf("shellCommand() args = %v", got)
+	}
+	useUserShell = true
+	if got := shellCommand("ls").Args; !reflect.DeepEqual(got[1:], []string{"-i", "-c", "ls"}) {
+		t.Errorf("shellCommand() with user shell args = %v", got)
+	}
+}
+
+func TestLoadShellAliasesCache(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	cached := aliasesCache{Dialect: dialectZsh, CheckedAt: time.Now(), Aliases: []string{"gst"}}
+	data, _ := json.Marshal(cached)
+	os.MkdirAll(filepath.Join(home, ".ask"), 0755)
+	os.WriteFile(aliasesCachePath(), data, 0644)
+
+	if got := loadShellAliases(dialectZsh); !got.has("gst") {
+		t.Errorf("loadShellAliases() ignored fresh cache: %+v", got)
+	}
+	// sh has no alias listing, and a dialect change invalidates the cache
+	if got := loadShellAliases(dialectSh); got.has("gst") || got.Dialect != dialectSh {
+		t.Errorf("loadShellAliases(sh) = %+v, want empty sh cache", got)
+	}
+}
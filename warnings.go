@@ -3,7 +3,9 @@ package main
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"regexp"
+	"strings"
 )
 
 type dangerousPattern struct {
@@ -16,8 +18,30 @@ type compiledPattern struct {
 	re       *regexp.Regexp
 	message  string
 	severity string
+	// anchored patterns only match at the start of a command, so that
+	// "echo 'rm -rf /'" isn't taken for rm.
+	anchored bool
+	// source is "built-in" or the file the rule was loaded from, and line
+	// its line in that file, if known.
+	source string
+	line   int
+	// scope limits where a user-defined rule applies.
+	scope *ruleScope
+	// structural describes what a built-in check without a pattern looks for.
+	structural string
 }
 
+// matches reports whether the pattern matches the text of one command.
+func (cp compiledPattern) matches(text string) bool {
+	if !cp.anchored {
+		return cp.re.MatchString(text) && cp.scope.applies(text)
+	}
+	loc := cp.re.FindStringIndex(text)
+	return loc != nil && loc[0] == 0 && cp.scope.applies(text)
+}
+
+// dangerousPatterns are matched against every command a command line runs
+// (see invocations.addList), starting at the command name.
 var dangerousPatterns = []dangerousPattern{
 	// File deletion - broad targets
 	{`\brm\s+.*-[^\s]*r[^\s]*\s+[/~*]`, "Recursive deletion targeting a broad path", "high"},
@@ -25,6 +49,8 @@ var dangerousPatterns = []dangerousPattern{
 	{`\brm\s+(-[^\s]*\s+)*\*`, "Deleting files with wildcard", "high"},
 	{`\brm\s+-[^\s]*f`, "Force file deletion (no confirmation)", "medium"},
 	{`\bsudo\s+rm\b`, "Deleting files as root", "high"},
+	{`\bfind\s+.*-delete\b`, "Deleting every file find matches", "medium"},
+	{`\bfind\s+.*-(exec|execdir|ok|okdir)\s+(\S*/)?rm\s`, "Deleting every file find matches", "medium"},
 
 	// Disk/filesystem
 	{`\bdd\s+if=`, "Direct disk write — may overwrite partitions", "high"},
@@ -33,6 +59,20 @@ var dangerousPatterns = []dangerousPattern{
 	// Permissions
 	{`\bchmod\s+777\b`, "Setting world-writable permissions", "medium"},
 	{`\bchmod\s+.*-R\b`, "Recursive permission change", "medium"},
+	{`\bchmod\s+(-\S+\s+)*([ugoa]*[+=][rwxXt]*s|0?[2-7][0-7]{3})\b`, "Sets the setuid/setgid bit — runs with the owner's privileges", "high"},
+	{`\bchown\s+(.*\s)?(-[^\s-]*R|--recursive)\b.*\s/((etc|usr|bin|sbin|lib|lib64|var|boot|opt|root|System|Library)(/\S*)?)?(\s|$)`, "Recursive ownership change on a system path", "high"},
+
+	// System configuration
+	{`.*\s\d*(>|>>|>\|)\s/etc/`, "Writes to a system configuration file in /etc", "medium"},
+	{`\btee\s+(-\S+\s+)*/etc/`, "Writes to a system configuration file in /etc", "medium"},
+	{`\b(cp|mv|install|ln|rsync)\s+.*\s/etc(/\S*)?$`, "Writes to a system configuration file in /etc", "medium"},
+	{`\bsed\s+(.*\s)?-i.*\s/etc/`, "Writes to a system configuration file in /etc", "medium"},
+	{`\bcrontab\s+(\S+\s+)*-[a-z]*r(\s|$)`, "Removes all of the user's cron jobs", "high"},
+	{`\b(ip6?tables|ebtables)\s+(.*\s)?(-F|--flush)(\s|$)`, "Flushes all firewall rules", "high"},
+	{`\bnft\s+flush\s+ruleset\b`, "Flushes all firewall rules", "high"},
+	{`^(shutdown|reboot|halt|poweroff)(\s|$)`, "Shuts down or reboots the machine", "high"},
+	{`\bsystemctl\s+(-\S+\s+)*(reboot|poweroff|halt)(\s|$)`, "Shuts down or reboots the machine", "high"},
+	{`\binit\s+[06]\b`, "Shuts down or reboots the machine", "high"},
 
 	// Git destructive
 	{`\bgit\s+reset\s+--hard\b`, "Discards all uncommitted changes", "medium"},
@@ -43,18 +83,48 @@ var dangerousPatterns = []dangerousPattern{
 	// Process
 	{`\bkill\s+-9\b`, "Forceful process termination (no cleanup)", "medium"},
 
-	// File truncation (redirect at start of command)
+	// Containers and infrastructure
+	{`\bdocker\s+system\s+prune\b.*\s(-[^\s-]*a[^\s-]*|--all)(\s|$)`, "Removes all unused Docker images, not just dangling ones", "medium"},
+	{`\bkubectl\s+(.*\s)?delete\s+(-\S+\s+([^-\s]\S*\s+)?)*(ns|namespaces?)(/|\s|$)`, "Deletes a Kubernetes namespace and everything in it", "high"},
+	{`\bterraform\s+(-\S+\s+)*(destroy|apply\s+(.*\s)?-destroy)(\s|$)`, "Destroys Terraform-managed infrastructure", "high"},
+
+	// Shell history
+	{`\bhistory\s+(.*\s)?-[a-z]*c`, "Clears the shell history", "medium"},
+
+	// File truncation (redirect with no command)
 	{`^\s*>`, "File truncation — will erase file contents", "high"},
+}
 
-	// SQL destructive
+// dangerousContentPatterns are matched anywhere in a command, including its
+// quoted arguments and heredocs: SQL usually reaches psql or mysql as a string.
+var dangerousContentPatterns = []dangerousPattern{
 	{`(?i)\bDROP\s+TABLE\b`, "Drops a database table permanently", "high"},
 	{`(?i)\bDROP\s+DATABASE\b`, "Drops an entire database permanently", "high"},
 	{`(?i)\bTRUNCATE\b`, "Truncates table data permanently", "high"},
-
-	// Fork bomb
-	{`:\(\)\s*\{.*\|.*\}`, "Potential fork bomb — may crash the system", "high"},
 }
 
+// Structural checks look at the shape of a command rather than its text.
+var (
+	forkBombWarning = compiledPattern{message: "Potential fork bomb — may crash the system", severity: "high",
+		source: builtinRules, structural: "a function that pipes into itself"}
+	pipeToShellWarning = compiledPattern{message: "Runs a downloaded script without letting you read it", severity: "high",
+		source: builtinRules, structural: "curl or wget piped or substituted into a shell"}
+	overwriteWarning = compiledPattern{message: "Redirect overwrites an existing file", severity: "medium",
+		source: builtinRules, structural: "> onto an existing, non-empty file"}
+)
+
+// structuralRules are listed by "ask rules list".
+var structuralRules = []compiledPattern{forkBombWarning, pipeToShellWarning, overwriteWarning,
+	secretsToNetworkWarning, foreignHostWarning, uploadWarning, printSecretWarning}
+
+// staticChecks turns off the checks that look at the filesystem, which
+// "ask lint" can't trust: a script is linted in a different directory from
+// the one it runs in.
+var staticChecks bool
+
+// builtinRules is the source of the compiled-in rules.
+const builtinRules = "built-in"
+
 var compiledPatterns []compiledPattern
 
 func init() {
@@ -63,33 +133,703 @@ func init() {
 			re:       regexp.MustCompile(dp.pattern),
 			message:  dp.message,
 			severity: dp.severity,
+			anchored: true,
+			source:   builtinRules,
+		})
+	}
+	for _, dp := range dangerousContentPatterns {
+		compiledPatterns = append(compiledPatterns, compiledPattern{
+			re:       regexp.MustCompile(dp.pattern),
+			message:  dp.message,
+			severity: dp.severity,
+			source:   builtinRules,
 		})
 	}
 }
 
+// activeDangerRules returns the built-in rules, the rules of the global and
+// project rules files (see rulesFileName) and those of the nearest .ask.yaml,
+// plus the errors of the files that failed to load.
+func activeDangerRules(dir string) ([]compiledPattern, []error) {
+	userRules, errs := loadUserRules(dir)
+	patterns := append(append([]compiledPattern(nil), compiledPatterns...), userRules...)
+	askFile, err := loadAskFile(dir)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	return append(patterns, askFile.dangerPatterns()...), errs
+}
+
+// checkDangerousCommand returns the active rules that match any command cmd
+// runs. Rules files that fail to load are reported once and skipped.
 func checkDangerousCommand(cmd string) []compiledPattern {
+	cwd, _ := os.Getwd()
+	patterns, errs := activeDangerRules(cwd)
+	reportRuleErrors(errs)
+	_, matched := matchDangerRules(cmd, patterns)
+	return matched
+}
+
+// matchDangerRules returns the commands cmd runs and the patterns that match
+// them. If cmd isn't valid shell the patterns are matched against its raw
+// text and the invocations are nil.
+func matchDangerRules(cmd string, patterns []compiledPattern) (*invocations, []compiledPattern) {
 	var matched []compiledPattern
-	for _, cp := range compiledPatterns {
-		if cp.re.MatchString(cmd) {
+	list, err := parseShell(cmd)
+	if err != nil {
+		for _, cp := range patterns {
+			if cp.re.MatchString(cmd) && cp.scope.applies(cmd) {
+				matched = append(matched, cp)
+			}
+		}
+		return nil, matched
+	}
+
+	inv := &invocations{}
+	inv.addList(list, 0)
+	if inv.forkBomb {
+		matched = append(matched, forkBombWarning)
+	}
+	if inv.pipeToShell {
+		matched = append(matched, pipeToShellWarning)
+	}
+	if inv.overwrites {
+		matched = append(matched, overwriteWarning)
+	}
+	matched = append(matched, inv.leaks...)
+	for _, cp := range patterns {
+		if inv.matches(cp) {
 			matched = append(matched, cp)
 		}
 	}
-	return matched
+	return inv, matched
+}
+
+// maxInvocationDepth bounds how deeply nested sh -c, eval and $(...) are
+// followed.
+const maxInvocationDepth = 8
+
+// invocations collects the text of every command a command line runs: each
+// command of its pipelines, subshells and groups, the commands run through
+// wrappers like sudo, xargs and find -exec, and the commands inside $(...),
+// eval and sh -c strings.
+type invocations struct {
+	commands    []string // one per command: its name (without path) and arguments
+	content     []string // heredoc bodies, only checked by content patterns
+	args        []string // the arguments of unknown commands, see plainArgs
+	quoted      []string // their arguments with whitespace, matched like commands
+	forkBomb    bool
+	pipeToShell bool              // a download is run by a shell
+	overwrites  bool              // a command's > redirect replaces an existing file
+	secrets     *secretUse        // the pipeline being added, with its substitutions
+	leaks       []compiledPattern // the exfiltration warnings of each pipeline
+}
+
+func (inv *invocations) matches(cp compiledPattern) bool {
+	for _, text := range inv.commands {
+		if cp.matches(text) {
+			return true
+		}
+	}
+	if cp.anchored {
+		// A command given to a program ask doesn't know how to unwrap
+		// (ssh host rm -rf /) is still caught anywhere in its arguments.
+		for _, text := range inv.args {
+			if cp.re.MatchString(text) && cp.scope.applies(text) {
+				return true
+			}
+		}
+		for _, text := range inv.quoted {
+			if cp.matches(text) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, text := range inv.content {
+		if cp.re.MatchString(text) && cp.scope.applies(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// addList adds the commands of list. A list inside a pipeline, like a
+// command substitution, shares the pipeline's secretUse; otherwise each
+// pipeline gets its own, so "cp .env.example .env && curl URL" doesn't tie
+// the file to the download.
+func (inv *invocations) addList(list *shellList, depth int) {
+	if pipesDownloadToShell(list) {
+		inv.pipeToShell = true
+	}
+	for _, pl := range list.Pipelines {
+		scoped := inv.secrets == nil
+		if scoped {
+			inv.secrets = &secretUse{}
+		}
+		inv.addCommands((&shellList{Pipelines: []*shellPipeline{pl}}).commands(), depth)
+		for _, src := range pipedScripts(pl) {
+			inv.addSource(src, depth+1)
+		}
+		if scoped {
+			for _, w := range inv.secrets.warnings() {
+				if !containsWarning(inv.leaks, w) {
+					inv.leaks = append(inv.leaks, w)
+				}
+			}
+			inv.secrets = nil
+		}
+	}
+}
+
+func (inv *invocations) addCommands(commands []*simpleCommand, depth int) {
+	for _, c := range commands {
+		for _, w := range append(append([]shellWord(nil), c.Assigns...), c.Words...) {
+			for _, src := range w.Substs {
+				inv.addSource(src, depth+1)
+			}
+		}
+		for _, r := range c.Redirects {
+			for _, src := range r.Target.Substs {
+				inv.addSource(src, depth+1)
+			}
+		}
+		if c.Func != "" && callsItselfInPipeline(c) {
+			inv.forkBomb = true
+		}
+		if !staticChecks && len(c.Words) > 0 && overwritesExistingFile(c.Redirects) {
+			inv.overwrites = true
+		}
+		if c.Group == nil {
+			inv.addCommand(c.Words, c.Redirects, depth)
+		}
+	}
+}
+
+// addSource parses src, a command string found inside another command, and
+// adds what it runs.
+func (inv *invocations) addSource(src string, depth int) {
+	if depth > maxInvocationDepth {
+		return
+	}
+	list, err := parseShell(src)
+	if err != nil {
+		inv.commands = append(inv.commands, strings.TrimSpace(src))
+		return
+	}
+	inv.addList(list, depth)
+}
+
+func (inv *invocations) addCommand(words []shellWord, redirects []shellRedirect, depth int) {
+	if depth > maxInvocationDepth {
+		return
+	}
+	inv.commands = append(inv.commands, renderInvocation(words, redirects))
+	inv.secrets.add(words, redirects)
+	for _, r := range redirects {
+		if r.Heredoc != "" {
+			inv.content = append(inv.content, r.Heredoc)
+		}
+	}
+	if len(words) == 0 {
+		return
+	}
+
+	name := filepath.Base(words[0].Value)
+	switch {
+	case name == "eval":
+		var args []string
+		for _, w := range words[1:] {
+			args = append(args, w.Value)
+		}
+		inv.addSource(strings.Join(args, " "), depth+1)
+	case shellInterpreters[name]:
+		if script, ok := shellScriptArg(words); ok {
+			inv.addSource(script, depth+1)
+		} else if readsScriptFromStdin(words) {
+			// sh <<EOF ... EOF and bash <<< "..." run their input
+			for _, script := range stdinText(redirects) {
+				inv.addSource(script, depth+1)
+			}
+		}
+	case codeInterpreters[interpreterName(name)] != "":
+		// perl -e 'system("rm -rf /")': the code isn't shell, but its
+		// strings are checked as commands, and the code as arguments
+		for _, code := range inlineCode(interpreterName(name), words) {
+			inv.args = append(inv.args, code)
+			for _, m := range codeStringRe.FindAllStringSubmatch(code, -1) {
+				inv.addSource(m[1]+m[2]+m[3], depth+1)
+			}
+		}
+	case name == "find":
+		for _, exec := range findExecCommands(words) {
+			inv.addCommand(exec, nil, depth+1)
+		}
+	case name == "ssh" || name == "su":
+		if script := remoteScript(name, words); script != "" {
+			inv.addSource(script, depth+1)
+		}
+	default:
+		if inner := unwrapCommand(name, words); len(inner) > 0 && commandWrappers[name].shell {
+			var parts []string
+			for _, w := range inner {
+				parts = append(parts, w.Value)
+			}
+			inv.addSource(strings.Join(parts, " "), depth+1)
+		} else if len(inner) > 0 {
+			inv.addCommand(inner, nil, depth+1)
+		} else if !dataCommands[name] {
+			args, quoted := plainArgs(words[1:])
+			if args != "" {
+				inv.args = append(inv.args, args)
+			}
+			inv.quoted = append(inv.quoted, quoted...)
+		}
+	}
+}
+
+// renderInvocation renders a command for the patterns: the command name
+// without its directory, then the arguments with quotes removed. Arguments
+// that contain spaces or shell operators stay quoted, so their content can't
+// look like more of the command.
+func renderInvocation(words []shellWord, redirects []shellRedirect) string {
+	var parts []string
+	for i, w := range words {
+		v := w.Value
+		if i == 0 {
+			v = filepath.Base(v)
+		}
+		if v == "" || strings.ContainsAny(v, " \t\n;&|") {
+			v = "'" + v + "'"
+		}
+		parts = append(parts, v)
+	}
+	for _, r := range redirects {
+		if r.Heredoc == "" {
+			parts = append(parts, r.Op+" "+r.Target.Value)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// shellInterpreters run the script given with -c.
+var shellInterpreters = map[string]bool{
+	"sh": true, "bash": true, "zsh": true, "dash": true, "ksh": true, "mksh": true, "ash": true, "fish": true,
+}
+
+// readsScriptFromStdin reports whether a shell reads its script from
+// standard input: it has no script file or -c, only options (sh -s, bash -x).
+func readsScriptFromStdin(words []shellWord) bool {
+	for _, w := range words[1:] {
+		v := w.Value
+		if v == "-s" || v == "--" {
+			return true
+		}
+		if !strings.HasPrefix(v, "-") && !strings.HasPrefix(v, "+") {
+			return false
+		}
+	}
+	return true
+}
+
+// stdinText returns the text the redirects feed to standard input: heredoc
+// bodies and <<< here-strings.
+func stdinText(redirects []shellRedirect) []string {
+	var texts []string
+	for _, r := range redirects {
+		switch {
+		case r.Heredoc != "":
+			texts = append(texts, r.Heredoc)
+		case r.Op == "<<<":
+			texts = append(texts, r.Target.Value)
+		}
+	}
+	return texts
+}
+
+// pipedScripts returns the text piped into a shell at the end of pl, where
+// it is known: echo 'rm -rf /' | sh, printf ... | bash, cat <<EOF | sh.
+func pipedScripts(pl *shellPipeline) []string {
+	n := len(pl.Commands)
+	if n < 2 || pl.Commands[n-1].Group != nil {
+		return nil
+	}
+	shell := unwrapAll(pl.Commands[n-1].Words)
+	if len(shell) == 0 || !shellInterpreters[filepath.Base(shell[0].Value)] || !readsScriptFromStdin(shell) {
+		return nil
+	}
+	var scripts []string
+	for _, c := range pl.Commands[:n-1] {
+		if c.Group != nil {
+			continue
+		}
+		words := unwrapAll(c.Words)
+		scripts = append(scripts, stdinText(c.Redirects)...)
+		if len(words) == 0 {
+			continue
+		}
+		switch filepath.Base(words[0].Value) {
+		case "echo", "printf", "print":
+			var parts []string
+			for _, w := range words[1:] {
+				if len(parts) == 0 && strings.HasPrefix(w.Value, "-") && !w.Quoted {
+					continue // echo -e, printf --
+				}
+				parts = append(parts, strings.ReplaceAll(w.Value, `\n`, "\n"))
+			}
+			scripts = append(scripts, strings.Join(parts, " "))
+		}
+	}
+	return scripts
+}
+
+// codeInterpreters are the languages that run code given with an option,
+// mapped to the option's letter: perl -e CODE, python -c CODE.
+var codeInterpreters = map[string]string{
+	"perl": "e", "ruby": "e", "node": "e", "lua": "e", "python": "c", "php": "r",
+}
+
+// codeStringRe matches the string literals and backticks of inline code.
+var codeStringRe = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"|'((?:[^'\\]|\\.)*)'|` + "`([^`]*)`")
+
+// interpreterName maps versioned names (python3, python3.12) onto the names
+// in codeInterpreters.
+func interpreterName(name string) string {
+	if strings.HasPrefix(name, "python") {
+		return "python"
+	}
+	return name
+}
+
+// inlineCode returns the code given to an interpreter on its command line,
+// with -e alone or at the end of a cluster (perl -ne, python -Bc).
+func inlineCode(name string, words []shellWord) []string {
+	letter := codeInterpreters[name]
+	var code []string
+	for i := 1; i < len(words); i++ {
+		v := words[i].Value
+		if !strings.HasPrefix(v, "-") || v == "-" || v == "--" {
+			break // a script file, or standard input
+		}
+		if v == "--eval" || !strings.HasPrefix(v, "--") && strings.HasSuffix(v, letter) {
+			if i+1 < len(words) {
+				code = append(code, words[i+1].Value)
+			}
+			i++
+		}
+	}
+	return code
+}
+
+// shellScriptArg returns the script of "sh -c SCRIPT" (or -ec, -lc, ...).
+func shellScriptArg(words []shellWord) (string, bool) {
+	for i := 1; i < len(words); i++ {
+		v := words[i].Value
+		if !strings.HasPrefix(v, "-") || strings.HasPrefix(v, "--") {
+			return "", false // a script file
+		}
+		if strings.ContainsRune(v, 'c') && i+1 < len(words) {
+			return words[i+1].Value, true
+		}
+	}
+	return "", false
+}
+
+// findExecCommands returns the commands of find's -exec, -execdir, -ok and
+// -okdir actions.
+func findExecCommands(words []shellWord) [][]shellWord {
+	var cmds [][]shellWord
+	for i := 1; i < len(words); i++ {
+		switch words[i].Value {
+		case "-exec", "-execdir", "-ok", "-okdir":
+			end := i + 1
+			for end < len(words) && words[end].Value != ";" && words[end].Value != "+" {
+				end++
+			}
+			if end > i+1 {
+				cmds = append(cmds, words[i+1:end])
+			}
+			i = end
+		}
+	}
+	return cmds
+}
+
+// wrapperSpec describes how a wrapper command like sudo or xargs finds the
+// command it runs in its arguments.
+type wrapperSpec struct {
+	opts      map[string]bool // options that take a value
+	sub       string          // a subcommand that must come first (docker exec)
+	skip      int             // arguments before the command (chroot's new root)
+	dashDash  bool            // the command only follows "--" (kubectl exec)
+	shell     bool            // the command words are joined and run by sh -c (watch)
+	elsewhere bool            // the command runs in a container or another root
+}
+
+// commandWrappers run the command given in their arguments.
+var commandWrappers = map[string]wrapperSpec{
+	"sudo": {opts: map[string]bool{"-u": true, "-g": true, "-C": true, "-p": true, "-U": true, "-h": true, "-D": true, "-r": true, "-t": true, "-T": true,
+		"--user": true, "--group": true, "--close-from": true, "--prompt": true, "--other-user": true, "--host": true, "--chdir": true, "--role": true, "--type": true, "--command-timeout": true}},
+	"doas":    {opts: map[string]bool{"-u": true, "-C": true}},
+	"env":     {opts: map[string]bool{"-u": true, "-C": true, "-S": true, "--unset": true, "--chdir": true}},
+	"nice":    {opts: map[string]bool{"-n": true, "--adjustment": true}},
+	"nohup":   {},
+	"time":    {opts: map[string]bool{"-f": true, "-o": true}},
+	"command": {},
+	"exec":    {opts: map[string]bool{"-a": true}},
+	"xargs":   {opts: map[string]bool{"-I": true, "-n": true, "-P": true, "-L": true, "-d": true, "-a": true, "-E": true, "-s": true}},
+	"timeout": {opts: map[string]bool{"-s": true, "-k": true, "--signal": true, "--kill-after": true}, skip: 1},
+	"watch":   {opts: map[string]bool{"-n": true}, shell: true},
+	"stdbuf":  {opts: map[string]bool{"-i": true, "-o": true, "-e": true}},
+	"ionice":  {opts: map[string]bool{"-c": true, "-n": true, "-p": true, "-P": true, "-u": true}},
+	"chroot":  {opts: map[string]bool{"--userspec": true, "--groups": true}, skip: 1, elsewhere: true},
+	"busybox": {},
+	"docker": {sub: "exec", skip: 1, opts: map[string]bool{"-e": true, "--env": true, "--env-file": true, "-u": true, "--user": true,
+		"-w": true, "--workdir": true, "--detach-keys": true}, elsewhere: true},
+	"podman": {sub: "exec", skip: 1, opts: map[string]bool{"-e": true, "--env": true, "--env-file": true, "-u": true, "--user": true,
+		"-w": true, "--workdir": true, "--detach-keys": true}, elsewhere: true},
+	"kubectl": {sub: "exec", dashDash: true, elsewhere: true},
+	"oc":      {sub: "exec", dashDash: true, elsewhere: true},
+}
+
+// optionTakesValue reports whether option v takes the next word as its
+// value: v is one of opts, or a cluster of short flags (sudo -iu root) whose
+// last flag is. A value attached to a short flag (-o0, -uroot) doesn't.
+func optionTakesValue(v string, opts map[string]bool) bool {
+	if opts[v] {
+		return true
+	}
+	if strings.HasPrefix(v, "--") || len(v) < 3 {
+		return false
+	}
+	for i := 1; i < len(v); i++ {
+		if opts["-"+v[i:i+1]] {
+			return i == len(v)-1
+		}
+	}
+	return false
+}
+
+// unwrapCommand returns the command a wrapper like sudo or xargs runs, or
+// nil if name isn't a wrapper or runs nothing.
+func unwrapCommand(name string, words []shellWord) []shellWord {
+	spec, ok := commandWrappers[name]
+	if !ok {
+		return nil
+	}
+	i := 1
+	if spec.sub != "" {
+		if (name == "docker" || name == "podman") && i < len(words) && words[i].Value == "container" {
+			i++
+		}
+		if i >= len(words) || words[i].Value != spec.sub {
+			return nil
+		}
+		i++
+	}
+	if spec.dashDash {
+		for ; i < len(words); i++ {
+			if words[i].Value == "--" {
+				return words[i+1:]
+			}
+		}
+		return nil
+	}
+	skipped := 0
+	for i < len(words) {
+		v := words[i].Value
+		switch {
+		case v == "--":
+			return words[i+1:]
+		case strings.HasPrefix(v, "-") && len(v) > 1:
+			if optionTakesValue(v, spec.opts) {
+				i++
+			}
+		case name == "env" && strings.Contains(v, "="):
+		case skipped < spec.skip:
+			skipped++
+		default:
+			return words[i:]
+		}
+		i++
+	}
+	return nil
+}
+
+// sshOptions are the ssh options that take a value.
+var sshOptions = map[string]bool{"-B": true, "-b": true, "-c": true, "-D": true, "-E": true, "-e": true, "-F": true, "-I": true, "-i": true,
+	"-J": true, "-L": true, "-l": true, "-m": true, "-O": true, "-o": true, "-p": true, "-Q": true, "-R": true, "-S": true, "-W": true, "-w": true}
+
+// remoteScript returns the command string ssh runs on the remote host, or
+// the one su runs with -c. The words of an ssh command are joined the way
+// the remote shell sees them.
+func remoteScript(name string, words []shellWord) string {
+	switch name {
+	case "ssh":
+		host := false
+		for i := 1; i < len(words); i++ {
+			v := words[i].Value
+			switch {
+			case !host && strings.HasPrefix(v, "-") && len(v) > 1:
+				if optionTakesValue(v, sshOptions) {
+					i++
+				}
+			case !host:
+				host = true
+			default:
+				var parts []string
+				for _, w := range words[i:] {
+					parts = append(parts, w.Value)
+				}
+				return strings.Join(parts, " ")
+			}
+		}
+	case "su":
+		for i := 1; i < len(words); i++ {
+			v := words[i].Value
+			if script, ok := strings.CutPrefix(v, "--command="); ok {
+				return script
+			}
+			if (v == "--command" || strings.HasPrefix(v, "-") && !strings.HasPrefix(v, "--") && strings.HasSuffix(v, "c")) && i+1 < len(words) {
+				return words[i+1].Value
+			}
+		}
+	}
+	return ""
+}
+
+// dataCommands take text, patterns or paths as arguments and never run
+// them, so their arguments aren't matched as commands (echo reboot).
+var dataCommands = map[string]bool{
+	"echo": true, "printf": true, "print": true, "grep": true, "egrep": true, "fgrep": true, "rg": true, "ag": true,
+	"ls": true, "cat": true, "less": true, "more": true, "head": true, "tail": true, "wc": true, "file": true, "stat": true,
+	"man": true, "which": true, "type": true, "whereis": true, "apropos": true, "tldr": true, "jq": true, "test": true, "[": true,
+}
+
+// plainArgs renders the arguments of a command that isn't a known wrapper.
+// Arguments that contain whitespace are returned apart, with any --option=
+// prefix removed: text like a commit message doesn't look like a command in
+// the middle of it, but a command string (tmux new 'rm -rf /') starts with
+// one.
+func plainArgs(words []shellWord) (string, []string) {
+	var parts, quoted []string
+	for _, w := range words {
+		switch {
+		case strings.ContainsAny(w.Value, " \t\n"):
+			text := w.Value
+			if opt, value, ok := strings.Cut(text, "="); ok && strings.HasPrefix(opt, "-") && !strings.ContainsAny(opt, " \t\n") {
+				text = value
+			}
+			quoted = append(quoted, strings.TrimSpace(text))
+		case w.Value != "":
+			parts = append(parts, w.Value)
+		}
+	}
+	return strings.Join(parts, " "), quoted
+}
+
+// callsItselfInPipeline reports whether the function c defines pipes into or
+// from itself, the shape of a fork bomb: :(){ :|:& };:
+func callsItselfInPipeline(c *simpleCommand) bool {
+	for _, pl := range c.Group.Pipelines {
+		if len(pl.Commands) < 2 {
+			continue
+		}
+		for _, cmd := range pl.Commands {
+			if cmd.name() == c.Func {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// downloaders fetch URLs; piping their output into a shell runs a script
+// nobody has read.
+var downloaders = map[string]bool{"curl": true, "wget": true, "fetch": true}
+
+// runName returns the name of the program words run, looking through
+// wrappers like sudo and env.
+func runName(words []shellWord) string {
+	if words = unwrapAll(words); len(words) > 0 {
+		return filepath.Base(words[0].Value)
+	}
+	return ""
+}
+
+// unwrapAll returns the command words run, looking through wrappers like
+// sudo and env: sudo env FOO=1 bash -s gives bash -s.
+func unwrapAll(words []shellWord) []shellWord {
+	return unwrapWhile(words, func(wrapperSpec) bool { return true })
+}
+
+// unwrapLocal is unwrapAll for the commands run on this machine: it stops at
+// wrappers like docker exec, whose command runs elsewhere.
+func unwrapLocal(words []shellWord) []shellWord {
+	return unwrapWhile(words, func(spec wrapperSpec) bool { return !spec.elsewhere })
+}
+
+// unwrapWhile looks through the wrappers of words that ok accepts.
+func unwrapWhile(words []shellWord, ok func(wrapperSpec) bool) []shellWord {
+	for len(words) > 0 {
+		name := filepath.Base(words[0].Value)
+		if spec, found := commandWrappers[name]; found && !ok(spec) {
+			return words
+		}
+		inner := unwrapCommand(name, words)
+		if len(inner) == 0 {
+			return words
+		}
+		words = inner
+	}
+	return nil
+}
+
+// pipesDownloadToShell reports whether list pipes curl or wget into a shell
+// (curl URL | sudo bash), or runs a shell on one ("bash <(curl URL)",
+// sh -c "$(wget -O- URL)").
+func pipesDownloadToShell(list *shellList) bool {
+	for _, pl := range list.Pipelines {
+		downloaded := false
+		for _, c := range pl.Commands {
+			if c.Group != nil && pipesDownloadToShell(c.Group) {
+				return true
+			}
+			name := runName(c.Words)
+			if downloaded && shellInterpreters[name] {
+				return true
+			}
+			if downloaders[name] {
+				downloaded = true
+			}
+			if shellInterpreters[name] || name == "source" || name == "." || name == "eval" {
+				for _, w := range c.Words[1:] {
+					for _, src := range w.Substs {
+						if fields := strings.Fields(src); len(fields) > 0 && downloaders[filepath.Base(fields[0])] {
+							return true
+						}
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// overwritesExistingFile reports whether a redirect replaces an existing,
+// non-empty file (see truncatedFiles).
+func overwritesExistingFile(redirects []shellRedirect) bool {
+	cwd, _ := os.Getwd()
+	paths, _ := truncatedFiles(redirects, cwd)
+	return len(paths) > 0
 }
 
 func printWarnings(warnings []compiledPattern) {
 	for _, w := range warnings {
-		var color string
+		color := colorYellow
 		if w.severity == "high" {
-			color = "\033[31m" // red
-		} else {
-			color = "\033[33m" // yellow
+			color = colorRed
 		}
-		fmt.Fprintf(os.Stderr, "%s  ⚠ Warning: %s\033[0m\n", color, w.message)
+		fmt.Fprintln(os.Stderr, paint(os.Stderr, color, "  ⚠ Warning: "+w.message))
 	}
 }
-
-func warnIfDangerous(cmd string) {
-	warnings := checkDangerousCommand(cmd)
-	printWarnings(warnings)
-}
@@ -1,12 +1,17 @@
 package main
 
-import "testing"
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func TestCheckDangerousCommand(t *testing.T) {
 	dangerous := []struct {
-		cmd         string
-		expectMin   int
-		desc        string
+		cmd       string
+		expectMin int
+		desc      string
 	}{
 		{"rm -rf /", 2, "recursive delete root"},
 		{"rm -rf ~", 2, "recursive delete home"},
@@ -93,3 +98,240 @@ func TestCheckDangerousCommandSeverity(t *testing.T) {
 		t.Errorf("kill -9 should be 'medium' severity, got %q", warnings[0].severity)
 	}
 }
+
+func TestCheckDangerousCommandProjectRules(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".ask.yaml"), []byte("forbidden_tools: [npm]\ndanger_rules:\n  - pattern: 'kubectl\\s+delete'\n    message: Deleting cluster resources\n    severity: high\n"), 0644)
+	orig, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(orig)
+
+	warnings := checkDangerousCommand("kubectl delete pod web")
+	if len(warnings) != 1 || warnings[0].severity != "high" {
+		t.Errorf("project danger rule: got %v, want one high warning", warnings)
+	}
+	if warnings := checkDangerousCommand("npm install"); len(warnings) != 1 {
+		t.Errorf("forbidden tool: got %d warnings, want 1", len(warnings))
+	}
+	if warnings := checkDangerousCommand("ls -la"); len(warnings) != 0 {
+		t.Errorf("safe command: got %d warnings, want 0", len(warnings))
+	}
+}
+
+func TestActiveDangerRulesAskFileError(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, ".ask.yaml"), []byte("danger_rules:\n  - pattern: '('\n"), 0644)
+
+	_, errs := activeDangerRules(dir)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), ".ask.yaml") {
+		t.Errorf("activeDangerRules() errors = %v, want the .ask.yaml error", errs)
+	}
+}
+
+func TestCheckDangerousCommandNested(t *testing.T) {
+	tests := []struct {
+		cmd, message string
+	}{
+		{`find / -name '*.tmp' -delete`, "Deleting every file find matches"},
+		{`find . -type d -exec rm -rf {} +`, "Recursive file deletion"},
+		{`ls | xargs rm -rf`, "Recursive file deletion"},
+		{`cat list | xargs -n 1 -I{} rm -f {}`, "Force file deletion (no confirmation)"},
+		{`sh -c "rm -rf /"`, "Recursive deletion targeting a broad path"},
+		{`bash -lc 'git push --force'`, "Force push may overwrite remote history"},
+		{`eval "kill -9 $PID"`, "Forceful process termination (no cleanup)"},
+		{`echo $(rm -rf ~)`, "Recursive deletion targeting a broad path"},
+		{`cd /tmp && (git reset --hard)`, "Discards all uncommitted changes"},
+		{`sudo -u root env FOO=1 /bin/rm -rf /var`, "Recursive deletion targeting a broad path"},
+		{`timeout 5 dd if=/dev/zero of=/dev/sda`, "Direct disk write — may overwrite partitions"},
+		{`psql -c "DROP TABLE users"`, "Drops a database table permanently"},
+		{"mysql db <<EOF\ndrop database prod;\nEOF", "Drops an entire database permanently"},
+		{"bash <<EOF\nchmod -R 777 /srv\nEOF", "Recursive permission change"},
+		{`echo 'rm -rf /' | sh`, "Recursive deletion targeting a broad path"},
+		{`printf 'rm -rf ~' | bash`, "Recursive deletion targeting a broad path"},
+		{`printf 'cd /\nrm -rf *' | sudo bash -s`, "Recursive deletion targeting a broad path"},
+		{"cat <<EOF | sh\nrm -rf /\nEOF", "Recursive deletion targeting a broad path"},
+		{`bash <<< "rm -rf /"`, "Recursive deletion targeting a broad path"},
+		{`watch 'rm -rf /'`, "Recursive deletion targeting a broad path"},
+		{`watch -n 5 "git push --force"`, "Force push may overwrite remote history"},
+		{`perl -e 'system("rm -rf /")'`, "Recursive deletion targeting a broad path"},
+		{`python3 -c 'import os; os.system("rm -rf /")'`, "Recursive deletion targeting a broad path"},
+		{`ruby -e '` + "`shutdown -h now`" + `'`, "Shuts down or reboots the machine"},
+		{`:(){ :|:& };:`, "Potential fork bomb — may crash the system"},
+		{`echo "unterminated && rm -rf /`, "Recursive deletion targeting a broad path"},
+	}
+	for _, tt := range tests {
+		found := false
+		for _, w := range checkDangerousCommand(tt.cmd) {
+			if w.message == tt.message {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("checkDangerousCommand(%q) should warn %q", tt.cmd, tt.message)
+		}
+	}
+}
+
+func TestCheckDangerousCommandWrappers(t *testing.T) {
+	cmds := []string{
+		`ssh host rm -rf /`,
+		`ssh host "rm -rf /"`,
+		`ssh -p 2222 -i key.pem user@host 'rm -rf /'`,
+		`su -c "rm -rf /"`,
+		`su - root -c 'rm -rf /'`,
+		`busybox rm -rf /`,
+		`chroot /mnt rm -rf /`,
+		`docker exec c rm -rf /`,
+		`docker container exec -u root -it c rm -rf /`,
+		`kubectl exec pod -- rm -rf /`,
+		`kubectl exec -n prod pod -c app -- rm -rf /`,
+		`stdbuf -o0 rm -rf /`,
+		`ionice -c3 rm -rf /`,
+		`sudo -iu root rm -rf /`,
+		`sudo -su root rm -rf /`,
+		`unknown-wrapper --flag rm -rf /`,
+		`unknown-wrapper --flag 'rm -rf /'`,
+		`tmux new-session -d 'rm -rf /'`,
+		`vagrant ssh -c "rm -rf /"`,
+		`gcloud compute ssh vm --command='rm -rf /'`,
+	}
+	for _, cmd := range cmds {
+		found := false
+		for _, w := range checkDangerousCommand(cmd) {
+			if w.message == "Recursive deletion targeting a broad path" {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("checkDangerousCommand(%q) should warn about the wrapped rm -rf /", cmd)
+		}
+	}
+}
+
+func TestCheckDangerousCommandQuotedText(t *testing.T) {
+	safe := []string{
+		`echo "rm -rf /"`,
+		`git commit -m "undo git push --force; rm -rf build"`,
+		`grep -r "kill -9" scripts/`,
+		`printf '%s\n' "> file"`,
+		`docker run --rm image`,
+		`ls /bin/rm`,
+		`echo 'rm -rf /' | grep rm`,
+		`echo 'rm -rf /' | bash deploy.sh`,
+	}
+	for _, cmd := range safe {
+		if warnings := checkDangerousCommand(cmd); len(warnings) > 0 {
+			t.Errorf("checkDangerousCommand(%q) = %q, want no warnings", cmd, warnings[0].message)
+		}
+	}
+}
+
+func TestCheckDangerousCommandCatalogue(t *testing.T) {
+	tests := []struct {
+		cmd, message string
+	}{
+		{`curl -fsSL https://example.com/install.sh | sh`, "Runs a downloaded script without letting you read it"},
+		{`wget -qO- https://example.com/x | sudo bash -s -- --yes`, "Runs a downloaded script without letting you read it"},
+		{`bash <(curl -s https://example.com/x)`, "Runs a downloaded script without letting you read it"},
+		{`sh -c "$(curl -fsSL https://example.com/x)"`, "Runs a downloaded script without letting you read it"},
+		{`find . -name '*.bak' -exec rm {} \;`, "Deleting every file find matches"},
+		{`find /tmp -type f -execdir /bin/rm -f {} +`, "Deleting every file find matches"},
+		{`sudo chown -R me:me /usr/local`, "Recursive ownership change on a system path"},
+		{`chown --recursive www /`, "Recursive ownership change on a system path"},
+		{`crontab -r`, "Removes all of the user's cron jobs"},
+		{`crontab -u alice -r`, "Removes all of the user's cron jobs"},
+		{`crontab -r -u alice`, "Removes all of the user's cron jobs"},
+		{`sudo iptables -F`, "Flushes all firewall rules"},
+		{`iptables -t nat --flush`, "Flushes all firewall rules"},
+		{`nft flush ruleset`, "Flushes all firewall rules"},
+		{`docker system prune -af`, "Removes all unused Docker images, not just dangling ones"},
+		{`docker system prune --all --volumes`, "Removes all unused Docker images, not just dangling ones"},
+		{`kubectl delete namespace staging`, "Deletes a Kubernetes namespace and everything in it"},
+		{`kubectl --context prod delete ns/payments`, "Deletes a Kubernetes namespace and everything in it"},
+		{`kubectl delete -n prod namespace x`, "Deletes a Kubernetes namespace and everything in it"},
+		{`kubectl delete --wait=false namespaces x --context prod`, "Deletes a Kubernetes namespace and everything in it"},
+		{`terraform destroy -auto-approve`, "Destroys Terraform-managed infrastructure"},
+		{`terraform apply -destroy`, "Destroys Terraform-managed infrastructure"},
+		{`terraform -chdir=infra destroy`, "Destroys Terraform-managed infrastructure"},
+		{`terraform apply -auto-approve -destroy`, "Destroys Terraform-managed infrastructure"},
+		{`systemctl --no-wall reboot`, "Shuts down or reboots the machine"},
+		{`ssh host sudo reboot`, "Shuts down or reboots the machine"},
+		{`sudo shutdown -h now`, "Shuts down or reboots the machine"},
+		{`reboot`, "Shuts down or reboots the machine"},
+		{`systemctl poweroff`, "Shuts down or reboots the machine"},
+		{`echo "127.0.0.1 dev" | sudo tee -a /etc/hosts`, "Writes to a system configuration file in /etc"},
+		{`echo nameserver 1.1.1.1 > /etc/resolv.conf`, "Writes to a system configuration file in /etc"},
+		{`sudo cp nginx.conf /etc/nginx/nginx.conf`, "Writes to a system configuration file in /etc"},
+		{`sed -i 's/a/b/' /etc/ssh/sshd_config`, "Writes to a system configuration file in /etc"},
+		{`chmod u+s /usr/local/bin/tool`, "Sets the setuid/setgid bit — runs with the owner's privileges"},
+		{`sudo chmod 4755 ./helper`, "Sets the setuid/setgid bit — runs with the owner's privileges"},
+		{`history -c`, "Clears the shell history"},
+	}
+	for _, tt := range tests {
+		found := false
+		for _, w := range checkDangerousCommand(tt.cmd) {
+			if w.message == tt.message {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("checkDangerousCommand(%q) should warn %q", tt.cmd, tt.message)
+		}
+	}
+}
+
+func TestCheckDangerousCommandCatalogueSafe(t *testing.T) {
+	safe := []string{
+		`curl -fsSL https://example.com/install.sh -o install.sh`,
+		`curl -s https://api.example.com | jq .`,
+		`find . -name '*.go' -exec grep -l TODO {} +`,
+		`chown -R me:me ./build`,
+		`crontab -l`,
+		`iptables -L`,
+		`docker system prune`,
+		`kubectl delete pod web-1`,
+		`kubectl delete -n prod pod ns`,
+		`crontab -u alice -l`,
+		`kubectl get namespaces`,
+		`terraform plan`,
+		`terraform plan -destroy`,
+		`npm run reboot`,
+		`git log --grep reboot`,
+		`systemctl status shutdown.target`,
+		`systemctl status reboot.target`,
+		`cat /etc/hosts`,
+		`cp /etc/hosts hosts.bak`,
+		`chmod 755 script.sh`,
+		`chmod 0644 file`,
+		`chmod +x script.sh`,
+		`history | grep git`,
+		`echo reboot`,
+	}
+	for _, cmd := range safe {
+		if warnings := checkDangerousCommand(cmd); len(warnings) > 0 {
+			t.Errorf("checkDangerousCommand(%q) = %q, want no warnings", cmd, warnings[0].message)
+		}
+	}
+}
+
+func TestCheckDangerousCommandOverwrite(t *testing.T) {
+	blastDir(t, map[string]string{"config.json": "{}", "empty.log": ""})
+	for cmd, want := range map[string]bool{
+		"echo {} > config.json":          true,
+		"jq . in.json >| config.json":    true,
+		"echo hi >> config.json":         false,
+		"echo hi > empty.log":            false,
+		"echo hi > new.txt":              false,
+		"make 2>/dev/null":               false,
+		"sort data | uniq > config.json": true,
+	} {
+		got := false
+		for _, w := range checkDangerousCommand(cmd) {
+			got = got || w.message == overwriteWarning.message
+		}
+		if got != want {
+			t.Errorf("checkDangerousCommand(%q) overwrite warning = %v, want %v", cmd, got, want)
+		}
+	}
+}
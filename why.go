@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const lastCommandFileName = "last-command"
+
+// lastCommandPath is written by the "ask init" shell hooks: the exit status
+// on the first line, the command on the rest.
+func lastCommandPath() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".ask", lastCommandFileName)
+}
+
+// readLastCommand returns the last command recorded by the shell integration
+// and its exit status.
+func readLastCommand() (string, int, error) {
+	data, err := os.ReadFile(lastCommandPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", 0, fmt.Errorf("no command recorded yet — enable shell integration with: eval \"$(ask init zsh)\" (or bash/fish)")
+		}
+		return "", 0, fmt.Errorf("reading last command: %w", err)
+	}
+	status, cmd, _ := strings.Cut(string(data), "\n")
+	code, err := strconv.Atoi(strings.TrimSpace(status))
+	cmd = strings.TrimSpace(cmd)
+	if err != nil || cmd == "" {
+		return "", 0, fmt.Errorf("malformed %s", lastCommandPath())
+	}
+	return cmd, code, nil
+}
+
+// askYesNo asks question on the terminal and reports whether the user
+// answered yes. It returns false when there is no terminal to ask on.
+func askYesNo(question string) bool {
+	in := confirmationInput()
+	if in == nil {
+		return false
+	}
+	if in != os.Stdin {
+		defer in.Close()
+	}
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	scanner := bufio.NewScanner(in)
+	scanner.Scan()
+	answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
+	return answer == "y" || answer == "yes"
+}
+
+// parseWhyResponse splits the model's answer into the diagnosis and the
+// command on its final "Fix:" line, if any.
+func parseWhyResponse(s string) (diagnosis, fix string) {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(strings.ToLower(line), "fix:") {
+			continue
+		}
+		fix = stripCodeFences(strings.TrimSpace(line[len("fix:"):]))
+		if strings.EqualFold(fix, "none") {
+			fix = ""
+		}
+		lines = append(lines[:i], lines[i+1:]...)
+		break
+	}
+	return stripMarkdown(strings.Join(lines, "\n")), fix
+}
+
+func buildWhyPrompt(cmd string, exitCode int, output string) string {
+	data := newPromptData("")
+	data.Command = cmd
+	data.ExitCode = exitCode
+	data.Input = sampleInput([]byte(output))
+	return renderTemplateOrDefault(whyTemplateName, data)
+}
+
+// runWhy implements "ask why": diagnose the last failed shell command and
+// offer a fixed command.
+func runWhy(model string, stats *Stats) error {
+	cmd, code, err := readLastCommand()
+	if err != nil {
+		return err
+	}
+	if code == 0 {
+		fmt.Printf("The last command succeeded: %s\n", cmd)
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Last command (exit %d): %s\n", code, cmd)
+	var output string
+	if outcome := guardCommand(cmd, nil); outcome.allowed && (outcome.confirmed || askYesNo("Re-run it to capture its error output?")) {
+		snapshotBeforeRun(cmd, outcome.blast)
+		runAudited("", cmd, outcome, func() error {
+			stdout, stderr, err := executeCommand(cmd)
+			output = stdout + stderr
+			return err
+		})
+	}
+
+	stats.RecordExplain(model)
+	spinner := NewSpinner("Diagnosing...")
+	spinner.Start()
+	result, err := generate(model, buildWhyPrompt(cmd, code, output))
+	spinner.Stop()
+	if err != nil {
+		return err
+	}
+
+	diagnosis, fix := parseWhyResponse(result)
+	fmt.Println(diagnosis)
+	if fix != "" {
+		stats.RecordOneshotCommand(model, "why: "+cmd, fix)
+		confirmAndRun(model, "why: "+cmd, fix, stats)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeLastCommand(t *testing.T, content string) {
+	t.Helper()
+	os.MkdirAll(filepath.Dir(lastCommandPath()), 0755)
+	if err := os.WriteFile(lastCommandPath(), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadLastCommand(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	if _, _, err := readLastCommand(); err == nil || !strings.Contains(err.Error(), "ask init") {
+		t.Errorf("readLastCommand() without a file = %v, want hint about ask init", err)
+	}
+
+	writeLastCommand(t, "127\nnpm run biuld\n")
+	cmd, code, err := readLastCommand()
+	if err != nil || cmd != "npm run biuld" || code != 127 {
+		t.Errorf("readLastCommand() = %q, %d, %v", cmd, code, err)
+	}
+
+	writeLastCommand(t, "1\nfor f in *; do\n  echo $f\ndone\n")
+	cmd, _, _ = readLastCommand()
+	if cmd != "for f in *; do\n  echo $f\ndone" {
+		t.Errorf("readLastCommand() multi-line = %q", cmd)
+	}
+
+	writeLastCommand(t, "oops\n")
+	if _, _, err := readLastCommand(); err == nil {
+		t.Error("readLastCommand() on malformed file should fail")
+	}
+}
+
+func TestParseWhyResponse(t *testing.T) {
+	tests := []struct {
+		name, input, diagnosis, fix string
+	}{
+		{
+			name:      "with fix",
+			input:     "The script name is misspelled.\n  npm has no script called biuld.\nFix: npm run build",
+			diagnosis: "The script name is misspelled.\n  npm has no script called biuld.",
+			fix:       "npm run build",
+		},
+		{
+			name:      "fix none",
+			input:     "The server is down.\nFix: none",
+			diagnosis: "The server is down.",
+			fix:       "",
+		},
+		{
+			name:      "no fix line",
+			input:     "The file does not exist.",
+			diagnosis: "The file does not exist.",
+			fix:       "",
+		},
+		{
+			name:      "backticked fix",
+			input:     "**Missing** directory.\nfix: `mkdir -p out`",
+			diagnosis: "Missing directory.",
+			fix:       "mkdir -p out",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diagnosis, fix := parseWhyResponse(tt.input)
+			if diagnosis != tt.diagnosis || fix != tt.fix {
+				t.Errorf("parseWhyResponse() = %q, %q; want %q, %q", diagnosis, fix, tt.diagnosis, tt.fix)
+			}
+		})
+	}
+}
+
+func TestBuildWhyPrompt(t *testing.T) {
+	prompt := buildWhyPrompt("make deploy", 2, "make: *** No rule to make target 'deploy'.")
+	for _, want := range []string{"Failed command: make deploy", "Exit status: 2", "No rule to make target", "Fix: "} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("buildWhyPrompt() missing %q", want)
+		}
+	}
+	if !strings.Contains(buildWhyPrompt("make deploy", 2, ""), "Output: not captured") {
+		t.Error("buildWhyPrompt() without output should say it wasn't captured")
+	}
+}
+
+func TestRunWhyLastCommandSucceeded(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	writeLastCommand(t, "0\nls\n")
+
+	var err error
+	out := captureStdout(t, func() { err = runWhy("test-model", newTestStats()) })
+	if err != nil || !strings.Contains(out, "succeeded") {
+		t.Errorf("runWhy() after success = %q, %v", out, err)
+	}
+}